@@ -5,382 +5,590 @@
 package boardgametracker
 
 import (
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"strings"
 	"time"
 
-	"cloud.google.com/go/firestore"
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/validation"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/helpers"
 	"github.com/owen-crook/board-game-tracker-go-common/pkg/documents"
 	"github.com/owen-crook/board-game-tracker-go-common/pkg/games"
 )
 
-// TODO: deprecate after OC-50 is completed in the UI
+// TODO: remove after OC-50 is completed in the UI, it is deprecated in favour of the v2 uploads, parses and scorecards
 func HandleParseScoreCard(s *ScoreService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// parse user making request (they should be authZ'd to get here, just want data for logging)
-		user, err := auth.GetUserFromRequest(c.Request)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "unable to parse user from request"})
+		actor, ok := actorFromContext(c)
+		if !ok {
+			return
 		}
-
-		// parse and validate the game
-		game := c.Param("game")
-		if !games.IsSupportedGame(games.Game(game)) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported game: %s", game)})
+		image, ok := formImage(c)
+		if !ok {
+			return
 		}
 
-		// parse image
-		c.Request.ParseMultipartForm(10 << 20) // 10MB
-		file, _, err := c.Request.FormFile("image")
+		// parse and save in one step, as v2 does with three requests
+		sessionId := c.PostForm("session_id")
+		document, err := s.ParseFromImage(c.Request.Context(), actor, c.Param("game"), image, formDate(c), sessionId)
 		if err != nil {
-			log.Printf("FormFile error: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+			apierror.Abort(c, err)
 			return
 		}
-		defer file.Close()
-
-		// parse the date, falling back to today
-		date := helpers.TimeAsCalendarDateOnly(time.Now())
-		dateStrFromRequest := c.PostForm("date")
-		if dateStrFromRequest != "" {
-			parsedDate, err := helpers.ParseFlexibleDate(dateStrFromRequest)
-			if err != nil {
-				log.Printf("Unable to parse date from request: %v", err)
-			} else {
-				date = helpers.TimeAsCalendarDateOnly(parsedDate)
-			}
+		input := ScorecardRequest{
+			ImageUploadMetadataID: document.ImageUploadMetadataID,
+			SessionID:             sessionId,
+			Game:                  document.Game,
+			Date:                  document.Date,
+			IsCompleted:           document.IsCompleted,
+			Location:              document.Location,
 		}
-
-		// read first 512 bytes to detect content type
-		header := make([]byte, 512)
-		n, err := file.Read(header)
+		if document.PlayerScores != nil {
+			input.PlayerScores = *document.PlayerScores
+		}
+		scorecard, err := s.CreateScorecard(c.Request.Context(), actor, input)
 		if err != nil {
-			log.Printf("Header error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read image header"})
+			apierror.Abort(c, err)
 			return
 		}
 
-		contentType := http.DetectContentType(header[:n])
+		c.JSON(http.StatusOK, scorecard)
+	}
+}
 
-		// Rewind reader before full read
-		if _, err := file.Seek(0, io.SeekStart); err != nil {
-			log.Printf("File rewinder error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rewind file"})
+func HandleParseScoreCardFromImage(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
 			return
 		}
-
-		imgBytes, err := io.ReadAll(file)
-		if err != nil {
-			log.Printf("Image read error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read image"})
+		image, ok := formImage(c)
+		if !ok {
 			return
 		}
 
-		// save the file to GCS, getting back the url
-		bucket, path, err := s.Repository.SaveImage(c.Request.Context(), imgBytes, contentType)
+		// the result is left for the user to review before it is saved
+		document, err := s.ParseFromImage(c.Request.Context(), actor, c.Param("game"), image, formDate(c), c.PostForm("session_id"))
 		if err != nil {
-			log.Printf("Image save error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Abort(c, err)
 			return
 		}
 
-		// start building out metadata struct that we will upload no matter what
-		md := documents.ImageUploadCreate{
-			ID:        uuid.New().String(),
-			Bucket:    bucket,
-			Path:      path,
-			CreatedBy: &user.Email,
-			CreatedAt: time.Now().In(time.UTC),
-		}
+		c.JSON(http.StatusOK, document)
+	}
+}
+
+// actorFromContext is the user verified and stored by the auth middleware,
+// with the role it was granted. It writes an error response and returns false
+// when there is none.
+func actorFromContext(c *gin.Context) (Actor, bool) {
+	user, ok := auth.RequireUser(c)
+	if !ok {
+		return Actor{}, false
+	}
+	return Actor{Email: user.Email, Role: auth.GetRoleFromContext(c)}, true
+}
+
+// formImage reads the image in the form's image field, or writes an error
+// response and returns false.
+func formImage(c *gin.Context) ([]byte, bool) {
+	c.Request.ParseMultipartForm(10 << 20) // 10MB
+	file, _, err := c.Request.FormFile("image")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			apierror.Abort(c, apierror.PayloadTooLarge("image_too_large", fmt.Sprintf("image must be at most %d bytes", tooLarge.Limit)))
+			return nil, false
+		}
+		apierror.Abort(c, apierror.Validation("image_required", "image is required"))
+		return nil, false
+	}
+	defer file.Close()
+
+	image, err := io.ReadAll(file)
+	if err != nil {
+		apierror.Abort(c, apierror.Internal("failed to read image", err))
+		return nil, false
+	}
+	if len(image) == 0 {
+		apierror.Abort(c, apierror.Validation("image_required", "image is required"))
+		return nil, false
+	}
+	return image, true
+}
 
-		// send the request to Gemini
-		text, err := GetTextFromLLM(c.Request.Context(), s, games.Game(game), imgBytes)
+// formDate is the calendar date in the form's date field, falling back to today.
+func formDate(c *gin.Context) time.Time {
+	date := helpers.TimeAsCalendarDateOnly(time.Now())
+	dateStrFromRequest := c.PostForm("date")
+	if dateStrFromRequest != "" {
+		parsedDate, err := helpers.ParseFlexibleDate(dateStrFromRequest)
 		if err != nil {
-			log.Printf("LLM Text Parsing Error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			logging.FromContext(c.Request.Context()).Error("Unable to parse date from request", "error", err)
 		} else {
-			md.LlmParsedContent = &text
+			date = helpers.TimeAsCalendarDateOnly(parsedDate)
 		}
+	}
+	return date
+}
 
-		// save the image upload metadata
-		err = s.Repository.SaveImageUpload(c.Request.Context(), &md)
-		if err != nil {
-			log.Printf("Image upload metadata error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// ScorecardRequest is the body accepted when creating a scorecard.
+type ScorecardRequest struct {
+	ImageUploadMetadataID string           `json:"image_upload_metadata_id"`
+	SessionID             string           `json:"session_id"`
+	Game                  string           `json:"game" binding:"required,supported_game"`
+	Date                  time.Time        `json:"date" binding:"required,game_date"`
+	IsCompleted           bool             `json:"is_completed"`
+	Location              *string          `json:"location" binding:"omitempty,max=200"`
+	PlayerScores          []map[string]any `json:"player_scores" binding:"required,min=1,scores_for=Game"`
+}
+
+// ScorecardUpdate is the body accepted when updating a scorecard. Nil fields are left unchanged.
+type ScorecardUpdate struct {
+	Game         *string           `json:"game" binding:"omitempty,supported_game"`
+	Date         *time.Time        `json:"date" binding:"omitempty,game_date"`
+	IsCompleted  *bool             `json:"is_completed"`
+	Location     *string           `json:"location" binding:"omitempty,max=200"`
+	PlayerScores *[]map[string]any `json:"player_scores" binding:"omitempty,min=1"`
+}
+
+func HandleCreateScoreCard(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scorecard, ok := createScorecard(c, s)
+		if !ok {
 			return
 		}
+		c.JSON(http.StatusOK, scorecard)
+	}
+}
 
-		// TODO: if GetTextFromLLM fails, we may want to exit the flow after we save
-		//       the metadata to prevent GenerateGameScorecardDocumentFromText from failing
+// createScorecard saves the scorecard in the request body for the v1 and v2
+// handlers, or writes an error response and returns false.
+func createScorecard(c *gin.Context, s *ScoreService) (*documents.ScorecardDocumentCreate, bool) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		return nil, false
+	}
 
-		// parse the content from the string into known struct
-		document, err := GenerateGameScorecardDocumentFromText(c.Request.Context(), md.ID, game, text, date, s)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// parse and validate request body
+	var input ScorecardRequest
+	if err := c.ShouldBindJSON(&input); err != nil {
+		apierror.Abort(c, validation.Error(err))
+		return nil, false
+	}
+	// the session in the query, which v1 clients send, wins over the body
+	if sessionId := c.Query("session_id"); sessionId != "" {
+		input.SessionID = sessionId
+	}
+
+	scorecard, err := s.CreateScorecard(c.Request.Context(), actor, input)
+	if err != nil {
+		apierror.Abort(c, err)
+		return nil, false
+	}
+	return scorecard, true
+}
+
+func HandleUpdateScoreCard(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := updateScorecard(c, s); !ok {
+			return
 		}
-		documentCreate := documents.ScorecardDocumentCreate{
-			ID:                    uuid.New().String(),
-			ImageUploadMetadataID: document.ImageUploadMetadataID,
-			Game:                  document.Game,
-			Date:                  document.Date,
-			PlayerScores:          document.PlayerScores,
-			Location:              document.Location,
-			IsCompleted:           document.IsCompleted,
-			CreatedBy:             &user.Email,
-			CreatedAt:             time.Now().In(time.UTC),
+		c.JSON(http.StatusOK, gin.H{"message": "Document updated successfully"})
+	}
+}
+
+// updateScorecard applies the request body to the scorecard in the
+// documentId path parameter for the v1 and v2 handlers, or writes an error
+// response and returns false.
+func updateScorecard(c *gin.Context, s *ScoreService) (*documents.ScorecardDocumentCreate, bool) {
+	actor, ok := actorFromContext(c)
+	if !ok {
+		return nil, false
+	}
+
+	// parse and validate the request body
+	var update ScorecardUpdate
+	if err := c.ShouldBindJSON(&update); err != nil {
+		apierror.Abort(c, validation.Error(err))
+		return nil, false
+	}
+
+	scorecard, err := s.UpdateScorecard(c.Request.Context(), actor, c.Param("documentId"), update)
+	if err != nil {
+		apierror.Abort(c, err)
+		return nil, false
+	}
+	return scorecard, true
+}
+
+func HandleDeleteScoreCard(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
+			return
+		}
+		if err := s.DeleteScorecard(c.Request.Context(), actor, c.Param("documentId")); err != nil {
+			apierror.Abort(c, err)
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"message": "Document deleted successfully"})
+	}
+}
 
-		// save the content to db
-		err = s.Repository.SaveGameScorecardDocument(c.Request.Context(), &documentCreate)
+func HandleGetRatings(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// ratings are tracked overall and per game
+		scope := ratingScopeOverall
+		if game := c.Query("game"); game != "" {
+			if !games.IsSupportedGame(games.Game(game)) {
+				apierror.Abort(c, apierror.Validation("unsupported_game", fmt.Sprintf("Unsupported game: %s", game)))
+				return
+			}
+			scope = game
+		}
+
+		ratings, err := s.Repository.ListPlayerRatings(c.Request.Context(), scope)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Abort(c, apierror.Internal("failed to list ratings", err))
+			return
 		}
 
-		c.JSON(http.StatusOK, documentCreate)
+		c.JSON(http.StatusOK, gin.H{"scope": scope, "ratings": ratings})
 	}
 }
 
-func HandleParseScoreCardFromImage(s *ScoreService) gin.HandlerFunc {
+func HandleGetRatingHistory(s *ScoreService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// parse user making request (they should be authZ'd to get here, just want data for logging)
-		user, err := auth.GetUserFromRequest(c.Request)
+		// player names are stored lowercased by the parser
+		player := strings.ToLower(c.Param("player"))
+
+		scope := ratingScopeOverall
+		if game := c.Query("game"); game != "" {
+			if !games.IsSupportedGame(games.Game(game)) {
+				apierror.Abort(c, apierror.Validation("unsupported_game", fmt.Sprintf("Unsupported game: %s", game)))
+				return
+			}
+			scope = game
+		}
+
+		history, err := s.Repository.ListRatingHistoryForPlayer(c.Request.Context(), player, scope)
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "unable to parse user from request"})
+			apierror.Abort(c, apierror.Internal("failed to list rating history", err))
+			return
 		}
-		// parse and validate the game
-		game := c.Param("game")
-		if !games.IsSupportedGame(games.Game(game)) {
-			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported game: %s", game)})
+
+		c.JSON(http.StatusOK, gin.H{"player": player, "scope": scope, "history": history})
+	}
+}
+
+func HandleRecomputeRatings(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// a zero start date replays every scorecard from scratch
+		if err := s.RecomputeRatingsFrom(c.Request.Context(), time.Time{}); err != nil {
+			apierror.Abort(c, err)
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"message": "Ratings recomputed successfully"})
+	}
+}
 
-		// parse image
-		c.Request.ParseMultipartForm(10 << 20) // 10MB
-		file, _, err := c.Request.FormFile("image")
-		if err != nil {
-			log.Printf("FormFile error: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "image is required"})
+func HandleGetHeadToHead(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// parse and validate the two players
+		playerA := c.Query("a")
+		playerB := c.Query("b")
+		if playerA == "" || playerB == "" {
+			apierror.Abort(c, apierror.Validation("invalid_players", "both a and b players are required"))
 			return
 		}
-		defer file.Close()
 
-		// parse the date, falling back to today
-		date := helpers.TimeAsCalendarDateOnly(time.Now())
-		dateStrFromRequest := c.PostForm("date")
-		if dateStrFromRequest != "" {
-			parsedDate, err := helpers.ParseFlexibleDate(dateStrFromRequest)
+		// parse the optional filters
+		var filter HeadToHeadFilter
+		if game := c.Query("game"); game != "" {
+			if !games.IsSupportedGame(games.Game(game)) {
+				apierror.Abort(c, apierror.Validation("unsupported_game", fmt.Sprintf("Unsupported game: %s", game)))
+				return
+			}
+			filter.Game = game
+		}
+		if from := c.Query("from"); from != "" {
+			parsedDate, err := helpers.ParseFlexibleDate(from)
 			if err != nil {
-				log.Printf("Unable to parse date from request: %v", err)
-			} else {
-				date = helpers.TimeAsCalendarDateOnly(parsedDate)
+				apierror.Abort(c, apierror.Validation("invalid_date", fmt.Sprintf("invalid from date: %s", from)))
+				return
+			}
+			filter.From = helpers.TimeAsCalendarDateOnly(parsedDate)
+		}
+		if to := c.Query("to"); to != "" {
+			parsedDate, err := helpers.ParseFlexibleDate(to)
+			if err != nil {
+				apierror.Abort(c, apierror.Validation("invalid_date", fmt.Sprintf("invalid to date: %s", to)))
+				return
 			}
+			filter.To = helpers.TimeAsCalendarDateOnly(parsedDate)
 		}
 
-		// read first 512 bytes to detect content type
-		header := make([]byte, 512)
-		n, err := file.Read(header)
+		stats, err := s.ComputeHeadToHead(c.Request.Context(), playerA, playerB, filter)
 		if err != nil {
-			log.Printf("Header error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read image header"})
+			apierror.Abort(c, err)
 			return
 		}
 
-		contentType := http.DetectContentType(header[:n])
+		c.JSON(http.StatusOK, stats)
+	}
+}
+
+// SessionRequest is the body accepted when creating a session.
+type SessionRequest struct {
+	Date      time.Time `json:"date" binding:"required,game_date"`
+	Location  string    `json:"location"`
+	Attendees []string  `json:"attendees"`
+	Notes     *string   `json:"notes"`
+}
+
+// SessionUpdate is the body accepted when updating a session. Nil fields are left unchanged.
+type SessionUpdate struct {
+	Date      *time.Time `json:"date" binding:"omitempty,game_date"`
+	Location  *string    `json:"location"`
+	Attendees *[]string  `json:"attendees"`
+	Notes     *string    `json:"notes"`
+}
 
-		// Rewind reader before full read
-		if _, err := file.Seek(0, io.SeekStart); err != nil {
-			log.Printf("File rewinder error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rewind file"})
+func HandleCreateSession(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
 			return
 		}
 
-		imgBytes, err := io.ReadAll(file)
-		if err != nil {
-			log.Printf("Image read error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read image"})
+		var request SessionRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Abort(c, validation.Error(err))
 			return
 		}
 
-		// save the file to GCS, getting back the url
-		bucket, path, err := s.Repository.SaveImage(c.Request.Context(), imgBytes, contentType)
+		session, err := s.CreateSession(c.Request.Context(), actor, request)
 		if err != nil {
-			log.Printf("Image save error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Abort(c, err)
 			return
 		}
+		c.JSON(http.StatusOK, session)
+	}
+}
 
-		// start building out metadata struct that we will upload no matter what
-		md := documents.ImageUploadCreate{
-			ID:        uuid.New().String(),
-			Bucket:    bucket,
-			Path:      path,
-			CreatedBy: &user.Email,
-			CreatedAt: time.Now().In(time.UTC),
+func HandleListSessions(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessions, err := s.Repository.ListSessions(c.Request.Context())
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to list sessions", err))
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+	}
+}
 
-		// send the request to Gemini
-		text, err := GetTextFromLLM(c.Request.Context(), s, games.Game(game), imgBytes)
+func HandleGetSession(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, err := s.GetSession(c.Request.Context(), c.Param("sessionId"))
 		if err != nil {
-			log.Printf("LLM Text Parsing Error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-		} else {
-			md.LlmParsedContent = &text
+			apierror.Abort(c, err)
+			return
 		}
+		c.JSON(http.StatusOK, session)
+	}
+}
 
-		// save the image upload metadata
-		err = s.Repository.SaveImageUpload(c.Request.Context(), &md)
+func HandleGetSessionSummary(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		summary, err := s.BuildSessionSummary(c.Request.Context(), c.Param("sessionId"))
 		if err != nil {
-			log.Printf("Image upload metadata error: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Abort(c, err)
 			return
 		}
+		c.JSON(http.StatusOK, summary)
+	}
+}
 
-		// TODO: if GetTextFromLLM fails, we may want to exit the flow after we save
-		//       the metadata to prevent GenerateGameScorecardDocumentFromText from failing
+func HandleUpdateSession(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
+			return
+		}
 
-		// parse the content from the string into known struct
-		document, err := GenerateGameScorecardDocumentFromText(c.Request.Context(), md.ID, game, text, date, s)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		var update SessionUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			apierror.Abort(c, validation.Error(err))
+			return
 		}
 
-		c.JSON(http.StatusOK, document)
+		if err := s.UpdateSession(c.Request.Context(), actor, c.Param("sessionId"), update); err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Session updated successfully"})
 	}
 }
 
-func HandleCreateScoreCard(s *ScoreService) gin.HandlerFunc {
+func HandleDeleteSession(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := s.DeleteSession(c.Request.Context(), c.Param("sessionId")); err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Session deleted successfully"})
+	}
+}
+
+func HandleLinkSessionScoreCard(s *ScoreService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// parse user making request (they should be authZ'd to get here, just want data for logging)
-		user, err := auth.GetUserFromRequest(c.Request)
+		ctx := c.Request.Context()
+		sessionId, documentId := c.Param("sessionId"), c.Param("documentId")
+
+		// unlinking only clears the link, the scorecard itself is kept
+		var err error
+		if c.Request.Method == http.MethodDelete {
+			err = s.UnlinkScorecard(ctx, sessionId, documentId)
+		} else {
+			err = s.LinkScorecard(ctx, sessionId, documentId)
+		}
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "unable to parse user from request"})
+			apierror.Abort(c, err)
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"message": "Session scorecards updated successfully"})
+	}
+}
 
-		// parse request body
-		var document documents.ScorecardDocumentRaw
-		if err := c.ShouldBindJSON(&document); err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+// LocationRequest is the body accepted when creating a location.
+type LocationRequest struct {
+	Name    string   `json:"name" binding:"required"`
+	Aliases []string `json:"aliases"`
+}
+
+// LocationUpdate is the body accepted when updating a location. Nil fields are left unchanged.
+type LocationUpdate struct {
+	Name    *string   `json:"name"`
+	Aliases *[]string `json:"aliases"`
+}
+
+// LocationMergeRequest names the location to fold into the one in the path.
+type LocationMergeRequest struct {
+	SourceID string `json:"source_id" binding:"required"`
+}
+
+func HandleCreateLocation(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
 			return
 		}
 
-		// TODO: add proper validation beyond typing
-		documentCreate := documents.ScorecardDocumentCreate{
-			ID:                    uuid.New().String(),
-			ImageUploadMetadataID: document.ImageUploadMetadataID,
-			Game:                  document.Game,
-			Date:                  document.Date,
-			PlayerScores:          document.PlayerScores,
-			Location:              document.Location,
-			IsCompleted:           document.IsCompleted,
-			CreatedBy:             &user.Email,
-			CreatedAt:             time.Now().In(time.UTC),
+		var request LocationRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Abort(c, validation.Error(err))
+			return
 		}
 
-		// save the content to db
-		err = s.Repository.SaveGameScorecardDocument(c.Request.Context(), &documentCreate)
+		location, err := s.CreateLocation(c.Request.Context(), actor, request)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			apierror.Abort(c, err)
+			return
 		}
-
-		c.JSON(http.StatusOK, documentCreate)
+		c.JSON(http.StatusOK, location)
 	}
 }
 
-func HandleUpdateScoreCard(s *ScoreService) gin.HandlerFunc {
+func HandleListLocations(s *ScoreService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// parse user making request (they should be authZ'd to get here, just want data for logging)
-		user, err := auth.GetUserFromRequest(c.Request)
+		locations, err := s.Repository.ListLocations(c.Request.Context())
 		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "unable to parse user from request"})
+			apierror.Abort(c, apierror.Internal("failed to list locations", err))
+			return
 		}
+		c.JSON(http.StatusOK, gin.H{"locations": locations})
+	}
+}
 
-		// parse and validate the documentId
-		documentId := c.Param("documentId")
-		exists, err := s.Repository.CheckDocumentExists(c.Request.Context(), "board-game-scorecards", documentId)
+func HandleGetLocation(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		location, err := s.GetLocation(c.Request.Context(), c.Param("locationId"))
 		if err != nil {
-			log.Printf("Error checking document existence: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check document existence"})
+			apierror.Abort(c, err)
 			return
 		}
-		if !exists {
-			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("Document with ID %s not found", documentId)})
+		c.JSON(http.StatusOK, location)
+	}
+}
+
+func HandleGetLocationStats(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		stats, err := s.ComputeLocationStats(c.Request.Context(), c.Param("locationId"))
+		if err != nil {
+			apierror.Abort(c, err)
 			return
 		}
+		c.JSON(http.StatusOK, stats)
+	}
+}
 
-		// parse the request body into GameScorecardDocumentUpdate
-		var update documents.ScorecardDocumentUpdate
-		if err := c.ShouldBindJSON(&update); err != nil {
-			log.Printf("Error binding JSON: %v", err)
-			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request body"})
+func HandleUpdateLocation(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
 			return
 		}
 
-		// handle updates
-		var updates []firestore.Update
-		if update.Game != nil {
-			if !games.IsSupportedGame(games.Game(*update.Game)) {
-				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Unsupported game: %s", *update.Game)})
-				return
-			}
-			updates = append(updates, firestore.Update{Path: "game", Value: *update.Game})
-		}
-		if update.Date != nil {
-			*update.Date = helpers.TimeAsCalendarDateOnly(*update.Date)
-			updates = append(updates, firestore.Update{Path: "date", Value: *update.Date})
-		}
-		if update.IsCompleted != nil {
-			updates = append(updates, firestore.Update{Path: "is_completed", Value: *update.IsCompleted})
+		var update LocationUpdate
+		if err := c.ShouldBindJSON(&update); err != nil {
+			apierror.Abort(c, validation.Error(err))
+			return
 		}
-		if update.Location != nil {
-			updates = append(updates, firestore.Update{Path: "location", Value: *update.Location})
 
-		}
-		if update.PlayerScores != nil {
-			// TODO (OC-24): pass through validation to ensure we have all the players and that each player score
-			// is valid, as if it was being passed through the LLM. To do this, I need to parse out
-			// the logic from GenerateGameScorecardDocumentFromText and
-			// put it into a function that can be called here.
-			updates = append(updates, firestore.Update{Path: "player_scores", Value: *update.PlayerScores})
-		}
-		if len(updates) == 0 {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "no updates provided"})
+		if err := s.UpdateLocation(c.Request.Context(), actor, c.Param("locationId"), update); err != nil {
+			apierror.Abort(c, err)
 			return
 		}
+		c.JSON(http.StatusOK, gin.H{"message": "Location updated successfully"})
+	}
+}
 
-		// add updated by and updated at
-		updates = append(updates, firestore.Update{Path: "updated_by", Value: user.Email})
-		updates = append(updates, firestore.Update{Path: "updated_at", Value: time.Now().In(time.UTC)})
-
-		// perform the update
-		_, err = s.Repository.FirestoreClient.Collection("board-game-scorecards").Doc(documentId).Update(c.Request.Context(), updates)
-		if err != nil {
-			log.Printf("Error updating document: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update scorecard"})
+func HandleDeleteLocation(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// scorecards keep their location text, so deleting only removes the registry entry
+		if err := s.DeleteLocation(c.Request.Context(), c.Param("locationId")); err != nil {
+			apierror.Abort(c, err)
 			return
 		}
-
-		c.JSON(http.StatusOK, gin.H{"message": "Document updated successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Location deleted successfully"})
 	}
 }
 
-func HandleDeleteScoreCard(s *ScoreService) gin.HandlerFunc {
+func HandleMergeLocations(s *ScoreService) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// parse user making request (they should be authZ'd to get here, just want data for logging)
-		_, err := auth.GetUserFromRequest(c.Request)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "unable to parse user from request"})
+		actor, ok := actorFromContext(c)
+		if !ok {
+			return
+		}
+
+		var request LocationMergeRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Abort(c, validation.Error(err))
+			return
 		}
 
-		err = DeleteGameScorecardAndMetadta(c.Request.Context(), s, c.Param("documentId"))
+		location, err := s.MergeLocations(c.Request.Context(), actor, c.Param("locationId"), request.SourceID)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("failed to delete scorecard %v", err.Error())})
+			apierror.Abort(c, err)
 			return
 		}
-		c.JSON(http.StatusOK, gin.H{"message": "Document deleted successfully"})
+		c.JSON(http.StatusOK, location)
 	}
 }
@@ -0,0 +1,124 @@
+// Purpose:
+// Handles the resource-oriented v2 scorecard API.
+// Like the v1 handlers, which stay until their sunset, these only adapt requests to ScoreService.
+
+package boardgametracker
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/validation"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/helpers"
+)
+
+// ParseRequest is the body accepted when parsing an uploaded image.
+type ParseRequest struct {
+	UploadID string     `json:"upload_id" binding:"required"`
+	Game     string     `json:"game" binding:"required,supported_game"`
+	Date     *time.Time `json:"date" binding:"omitempty,game_date"`
+}
+
+func HandleV2CreateScorecard(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scorecard, ok := createScorecard(c, s)
+		if !ok {
+			return
+		}
+		c.Header("Location", scorecardLocation(c, scorecard.ID))
+		c.JSON(http.StatusCreated, scorecard)
+	}
+}
+
+func HandleV2GetScorecard(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scorecard, err := s.GetScorecard(c.Request.Context(), c.Param("documentId"))
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, scorecard)
+	}
+}
+
+func HandleV2UpdateScorecard(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scorecard, ok := updateScorecard(c, s)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, scorecard)
+	}
+}
+
+func HandleV2DeleteScorecard(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
+			return
+		}
+		if err := s.DeleteScorecard(c.Request.Context(), actor, c.Param("documentId")); err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		c.Status(http.StatusNoContent)
+	}
+}
+
+func HandleV2CreateUpload(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
+			return
+		}
+		image, ok := formImage(c)
+		if !ok {
+			return
+		}
+
+		upload, err := s.UploadImage(c.Request.Context(), actor, image, c.PostForm("session_id"))
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		c.JSON(http.StatusCreated, upload)
+	}
+}
+
+func HandleV2CreateParse(s *ScoreService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		actor, ok := actorFromContext(c)
+		if !ok {
+			return
+		}
+
+		// parse and validate request body
+		var request ParseRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Abort(c, validation.Error(err))
+			return
+		}
+
+		// the date defaults to today, like the v1 form field
+		date := helpers.TimeAsCalendarDateOnly(time.Now())
+		if request.Date != nil {
+			date = helpers.TimeAsCalendarDateOnly(*request.Date)
+		}
+
+		document, err := s.ParseUpload(c.Request.Context(), actor, request.UploadID, request.Game, date)
+		if err != nil {
+			apierror.Abort(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, document)
+	}
+}
+
+// scorecardLocation is the v2 URL of a scorecard, relative to the collection
+// the request was made to.
+func scorecardLocation(c *gin.Context, documentId string) string {
+	return strings.TrimSuffix(c.Request.URL.Path, "/") + "/" + documentId
+}
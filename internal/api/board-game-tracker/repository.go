@@ -10,9 +10,14 @@ import (
 	"errors"
 	"fmt"
 	"mime"
+	"sort"
+	"time"
 
 	firestore "cloud.google.com/go/firestore"
 	"github.com/google/uuid"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/metrics"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/tracing"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/gcs"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/helpers"
 	"github.com/owen-crook/board-game-tracker-go-common/pkg/documents"
@@ -22,6 +27,59 @@ import (
 
 const bgtBucket = "owencrook-dot-com"
 
+// SessionDocument is a game night that groups the scorecards played together.
+type SessionDocument struct {
+	ID        string     `firestore:"id" json:"id"`
+	Date      time.Time  `firestore:"date" json:"date"`
+	Location  string     `firestore:"location" json:"location"`
+	Attendees []string   `firestore:"attendees" json:"attendees"`
+	Notes     *string    `firestore:"notes" json:"notes"`
+	CreatedBy *string    `firestore:"created_by" json:"created_by"`
+	CreatedAt time.Time  `firestore:"created_at" json:"created_at"`
+	UpdatedBy *string    `firestore:"updated_by,omitempty" json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `firestore:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// LocationDocument is a canonical place games are played. Aliases are stored
+// normalized so free text from scorecards can be matched against them.
+type LocationDocument struct {
+	ID        string     `firestore:"id" json:"id"`
+	Name      string     `firestore:"name" json:"name"`
+	Aliases   []string   `firestore:"aliases" json:"aliases"`
+	CreatedBy *string    `firestore:"created_by" json:"created_by"`
+	CreatedAt time.Time  `firestore:"created_at" json:"created_at"`
+	UpdatedBy *string    `firestore:"updated_by,omitempty" json:"updated_by,omitempty"`
+	UpdatedAt *time.Time `firestore:"updated_at,omitempty" json:"updated_at,omitempty"`
+}
+
+// PlayerRatingDocument is the current rating of a player within a scope, where
+// the scope is either "overall" or the name of a game.
+type PlayerRatingDocument struct {
+	ID              string    `firestore:"id" json:"id"`
+	Player          string    `firestore:"player" json:"player"`
+	Scope           string    `firestore:"scope" json:"scope"`
+	Rating          float64   `firestore:"rating" json:"rating"`
+	GamesPlayed     int       `firestore:"games_played" json:"games_played"`
+	Wins            int       `firestore:"wins" json:"wins"`
+	LastScorecardID string    `firestore:"last_scorecard_id" json:"last_scorecard_id"`
+	LastPlayedAt    time.Time `firestore:"last_played_at" json:"last_played_at"`
+	UpdatedAt       time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// RatingHistoryDocument records how a single scorecard moved a player's rating.
+type RatingHistoryDocument struct {
+	ID           string    `firestore:"id" json:"id"`
+	Player       string    `firestore:"player" json:"player"`
+	Scope        string    `firestore:"scope" json:"scope"`
+	ScorecardID  string    `firestore:"scorecard_id" json:"scorecard_id"`
+	Date         time.Time `firestore:"date" json:"date"`
+	RatingBefore float64   `firestore:"rating_before" json:"rating_before"`
+	RatingAfter  float64   `firestore:"rating_after" json:"rating_after"`
+	GamesPlayed  int       `firestore:"games_played" json:"games_played"`
+	Wins         int       `firestore:"wins" json:"wins"`
+	CreatedAt    time.Time `firestore:"created_at" json:"created_at"`
+}
+
 type Storage struct {
 	FirestoreClient *firestore.Client
 	GCSClient       *gcs.Client
@@ -35,7 +93,9 @@ func constructImagePath(imageId, ext string) string {
 	return fmt.Sprintf("board-game-tracker/uploads/images/%s%s", imageId, ext)
 }
 
-func (s *Storage) SaveImage(ctx context.Context, image []byte, contentType string) (string, string, error) {
+func (s *Storage) SaveImage(ctx context.Context, image []byte, contentType string) (_ string, _ string, err error) {
+	ctx, span := tracing.Start(ctx, "gcs.SaveImage")
+	defer tracing.End(span, &err)
 	// determine file extension
 	exts, err := mime.ExtensionsByType(contentType)
 	if err != nil {
@@ -53,24 +113,83 @@ func (s *Storage) SaveImage(ctx context.Context, image []byte, contentType strin
 	if err != nil {
 		return "", "", err
 	}
+	logging.FromContext(ctx).Info("Saved image", "bucket", bgtBucket, "path", path, "bytes", len(image))
+	metrics.ObserveImageUpload(len(image))
 	return bgtBucket, path, nil
 }
 
-func (s *Storage) DeleteImage(ctx context.Context, path string) error {
+func (s *Storage) ReadImage(ctx context.Context, path string) (_ []byte, err error) {
+	ctx, span := tracing.Start(ctx, "gcs.ReadImage")
+	defer tracing.End(span, &err)
+	return s.GCSClient.ReadFile(ctx, bgtBucket, path)
+}
+
+func (s *Storage) DeleteImage(ctx context.Context, path string) (err error) {
+	ctx, span := tracing.Start(ctx, "gcs.DeleteImage")
+	defer tracing.End(span, &err)
 	return s.GCSClient.DeleteFile(ctx, bgtBucket, path)
 }
 
-func (s *Storage) SaveImageUpload(ctx context.Context, metadata *documents.ImageUploadCreate) error {
-	_, err := s.FirestoreClient.Collection("board-game-image-uploads").Doc(metadata.ID).Set(ctx, metadata)
+// CheckFirestore reads at most one scorecard, which is enough to prove the
+// database is reachable and readable with our credentials.
+func (s *Storage) CheckFirestore(ctx context.Context) error {
+	_, err := s.FirestoreClient.Collection("board-game-scorecards").Limit(1).Select().Documents(ctx).GetAll()
 	return err
 }
 
-func (s *Storage) SaveGameScorecardDocument(ctx context.Context, doc *documents.ScorecardDocumentCreate) error {
-	_, err := s.FirestoreClient.Collection("board-game-scorecards").Doc(doc.ID).Set(ctx, doc)
+// CheckBucket confirms the image bucket is reachable.
+func (s *Storage) CheckBucket(ctx context.Context) error {
+	return s.GCSClient.CheckBucket(ctx, bgtBucket)
+}
+
+func (s *Storage) SaveImageUpload(ctx context.Context, metadata *documents.ImageUploadCreate) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.SaveImageUpload")
+	defer tracing.End(span, &err)
+	_, err = s.FirestoreClient.Collection("board-game-image-uploads").Doc(metadata.ID).Set(ctx, metadata)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("Saved image upload", "image_upload_id", metadata.ID)
+	return nil
+}
+
+func (s *Storage) GetImageUpload(ctx context.Context, imageUploadId string) (_ *documents.ImageUploadCreate, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.GetImageUpload")
+	defer tracing.End(span, &err)
+	snapshot, err := s.GetDocument(ctx, "board-game-image-uploads", imageUploadId)
+	if err != nil {
+		return nil, err
+	}
+	var upload documents.ImageUploadCreate
+	if err := snapshot.DataTo(&upload); err != nil {
+		return nil, err
+	}
+	return &upload, nil
+}
+
+// SetImageUploadParsedContent keeps the text the llm read from an uploaded image.
+func (s *Storage) SetImageUploadParsedContent(ctx context.Context, imageUploadId, text string) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.SetImageUploadParsedContent")
+	defer tracing.End(span, &err)
+	_, err = s.FirestoreClient.Collection("board-game-image-uploads").Doc(imageUploadId).Update(ctx, []firestore.Update{{Path: "llm_parsed_content", Value: text}})
 	return err
 }
 
-func (s *Storage) GetDocument(ctx context.Context, collection, documentId string) (*firestore.DocumentSnapshot, error) {
+func (s *Storage) SaveGameScorecardDocument(ctx context.Context, doc *documents.ScorecardDocumentCreate) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.SaveGameScorecardDocument")
+	defer tracing.End(span, &err)
+	_, err = s.FirestoreClient.Collection("board-game-scorecards").Doc(doc.ID).Set(ctx, doc)
+	if err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("Saved scorecard", "scorecard_id", doc.ID, "game", doc.Game)
+	metrics.ObserveScorecardCreated(doc.Game)
+	return nil
+}
+
+func (s *Storage) GetDocument(ctx context.Context, collection, documentId string) (_ *firestore.DocumentSnapshot, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.GetDocument")
+	defer tracing.End(span, &err)
 	reference := s.FirestoreClient.Collection(collection).Doc(documentId)
 	snapshot, err := reference.Get(ctx)
 	if err != nil {
@@ -90,8 +209,508 @@ func (s *Storage) CheckDocumentExists(ctx context.Context, collection, documentI
 	return snapshot.Exists(), nil
 }
 
-func (s *Storage) DeleteDocument(ctx context.Context, collection, documentId string) error {
+func (s *Storage) DeleteDocument(ctx context.Context, collection, documentId string) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.DeleteDocument")
+	defer tracing.End(span, &err)
 	reference := s.FirestoreClient.Collection(collection).Doc(documentId)
-	_, err := reference.Delete(ctx)
+	_, err = reference.Delete(ctx)
+	return err
+}
+
+func (s *Storage) GetGameScorecardDocument(ctx context.Context, documentId string) (_ *documents.ScorecardDocumentCreate, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.GetGameScorecardDocument")
+	defer tracing.End(span, &err)
+	snapshot, err := s.GetDocument(ctx, "board-game-scorecards", documentId)
+	if err != nil {
+		return nil, err
+	}
+	var scorecard documents.ScorecardDocumentCreate
+	if err := snapshot.DataTo(&scorecard); err != nil {
+		return nil, fmt.Errorf("failed to decode scorecard: %w", err)
+	}
+	return &scorecard, nil
+}
+
+// ListGameScorecardsSince returns every scorecard dated on or after from, oldest first.
+func (s *Storage) ListGameScorecardsSince(ctx context.Context, from time.Time) (_ []documents.ScorecardDocumentCreate, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ListGameScorecardsSince")
+	defer tracing.End(span, &err)
+	snapshots, err := s.FirestoreClient.Collection("board-game-scorecards").
+		Where("date", ">=", from).
+		OrderBy("date", firestore.Asc).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list scorecards: %w", err)
+	}
+
+	scorecards := make([]documents.ScorecardDocumentCreate, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var scorecard documents.ScorecardDocumentCreate
+		if err := snapshot.DataTo(&scorecard); err != nil {
+			return nil, fmt.Errorf("failed to decode scorecard %s: %w", snapshot.Ref.ID, err)
+		}
+		scorecards = append(scorecards, scorecard)
+	}
+	return scorecards, nil
+}
+
+// ListRatingHistoryBefore returns every rating history entry dated strictly
+// before before.
+func (s *Storage) ListRatingHistoryBefore(ctx context.Context, before time.Time) (_ []RatingHistoryDocument, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ListRatingHistoryBefore")
+	defer tracing.End(span, &err)
+	snapshots, err := s.FirestoreClient.Collection("board-game-rating-history").
+		Where("date", "<", before).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rating history: %w", err)
+	}
+	return decodeRatingHistory(snapshots)
+}
+
+func (s *Storage) ListRatingHistoryForPlayer(ctx context.Context, player, scope string) (_ []RatingHistoryDocument, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ListRatingHistoryForPlayer")
+	defer tracing.End(span, &err)
+	snapshots, err := s.FirestoreClient.Collection("board-game-rating-history").
+		Where("player", "==", player).
+		Where("scope", "==", scope).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list rating history: %w", err)
+	}
+	history, err := decodeRatingHistory(snapshots)
+	if err != nil {
+		return nil, err
+	}
+	// games played is cumulative, so it orders entries even when several share a date
+	sort.Slice(history, func(i, j int) bool { return history[i].GamesPlayed < history[j].GamesPlayed })
+	return history, nil
+}
+
+func decodeRatingHistory(snapshots []*firestore.DocumentSnapshot) ([]RatingHistoryDocument, error) {
+	history := make([]RatingHistoryDocument, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var entry RatingHistoryDocument
+		if err := snapshot.DataTo(&entry); err != nil {
+			return nil, fmt.Errorf("failed to decode rating history %s: %w", snapshot.Ref.ID, err)
+		}
+		history = append(history, entry)
+	}
+	return history, nil
+}
+
+// ReplaceRatingHistorySince deletes every rating history entry dated on or after
+// from and writes entries in its place.
+func (s *Storage) ReplaceRatingHistorySince(ctx context.Context, from time.Time, entries []RatingHistoryDocument) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ReplaceRatingHistorySince")
+	defer tracing.End(span, &err)
+	collection := s.FirestoreClient.Collection("board-game-rating-history")
+	stale, err := collection.Where("date", ">=", from).Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to list rating history: %w", err)
+	}
+
+	writer := s.FirestoreClient.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, snapshot := range stale {
+		job, err := writer.Delete(snapshot.Ref)
+		if err != nil {
+			writer.End()
+			return fmt.Errorf("failed to queue rating history delete: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	writer.Flush()
+	for _, entry := range entries {
+		job, err := writer.Set(collection.Doc(entry.ID), entry)
+		if err != nil {
+			writer.End()
+			return fmt.Errorf("failed to queue rating history write: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	writer.End()
+
+	return bulkWriterJobsError(jobs)
+}
+
+func (s *Storage) ListPlayerRatings(ctx context.Context, scope string) (_ []PlayerRatingDocument, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ListPlayerRatings")
+	defer tracing.End(span, &err)
+	snapshots, err := s.FirestoreClient.Collection("board-game-ratings").
+		Where("scope", "==", scope).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ratings: %w", err)
+	}
+	ratings, err := decodePlayerRatings(snapshots)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(ratings, func(i, j int) bool { return ratings[i].Rating > ratings[j].Rating })
+	return ratings, nil
+}
+
+// ListAllPlayerRatings returns the current rating of every player in every scope.
+func (s *Storage) ListAllPlayerRatings(ctx context.Context) (_ []PlayerRatingDocument, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ListAllPlayerRatings")
+	defer tracing.End(span, &err)
+	snapshots, err := s.FirestoreClient.Collection("board-game-ratings").Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list ratings: %w", err)
+	}
+	return decodePlayerRatings(snapshots)
+}
+
+func decodePlayerRatings(snapshots []*firestore.DocumentSnapshot) ([]PlayerRatingDocument, error) {
+	ratings := make([]PlayerRatingDocument, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var rating PlayerRatingDocument
+		if err := snapshot.DataTo(&rating); err != nil {
+			return nil, fmt.Errorf("failed to decode rating %s: %w", snapshot.Ref.ID, err)
+		}
+		ratings = append(ratings, rating)
+	}
+	return ratings, nil
+}
+
+// ReplacePlayerRatings writes ratings and removes any stored rating that is no
+// longer present, e.g. a player whose only scorecard was deleted.
+func (s *Storage) ReplacePlayerRatings(ctx context.Context, ratings []PlayerRatingDocument) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ReplacePlayerRatings")
+	defer tracing.End(span, &err)
+	collection := s.FirestoreClient.Collection("board-game-ratings")
+	existing, err := collection.Documents(ctx).GetAll()
+	if err != nil {
+		return fmt.Errorf("failed to list ratings: %w", err)
+	}
+
+	keep := make(map[string]bool, len(ratings))
+	writer := s.FirestoreClient.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, rating := range ratings {
+		keep[rating.ID] = true
+		job, err := writer.Set(collection.Doc(rating.ID), rating)
+		if err != nil {
+			writer.End()
+			return fmt.Errorf("failed to queue rating write: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	for _, snapshot := range existing {
+		if keep[snapshot.Ref.ID] {
+			continue
+		}
+		job, err := writer.Delete(snapshot.Ref)
+		if err != nil {
+			writer.End()
+			return fmt.Errorf("failed to queue rating delete: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	writer.End()
+
+	return bulkWriterJobsError(jobs)
+}
+
+// LeaseDocument marks work that only one instance may do at a time.
+type LeaseDocument struct {
+	Holder    string    `firestore:"holder"`
+	ExpiresAt time.Time `firestore:"expires_at"`
+}
+
+// AcquireLease takes the named lease for holder until ttl from now, reporting
+// false while another holder has it. Leases whose holder died expire.
+func (s *Storage) AcquireLease(ctx context.Context, name, holder string, ttl time.Duration) (_ bool, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.AcquireLease")
+	defer tracing.End(span, &err)
+	reference := s.FirestoreClient.Collection("board-game-leases").Doc(name)
+	acquired := false
+	err = s.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		acquired = false
+		now := time.Now().UTC()
+		snapshot, err := tx.Get(reference)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			var lease LeaseDocument
+			if err := snapshot.DataTo(&lease); err != nil {
+				return err
+			}
+			if lease.Holder != holder && now.Before(lease.ExpiresAt) {
+				return nil
+			}
+		}
+		acquired = true
+		return tx.Set(reference, LeaseDocument{Holder: holder, ExpiresAt: now.Add(ttl)})
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to acquire lease %s: %w", name, err)
+	}
+	return acquired, nil
+}
+
+// ReleaseLease gives up the named lease if holder still has it.
+func (s *Storage) ReleaseLease(ctx context.Context, name, holder string) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ReleaseLease")
+	defer tracing.End(span, &err)
+	reference := s.FirestoreClient.Collection("board-game-leases").Doc(name)
+	return s.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snapshot, err := tx.Get(reference)
+		if status.Code(err) == codes.NotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var lease LeaseDocument
+		if err := snapshot.DataTo(&lease); err != nil {
+			return err
+		}
+		if lease.Holder != holder {
+			return nil
+		}
+		return tx.Delete(reference)
+	})
+}
+
+func bulkWriterJobsError(jobs []*firestore.BulkWriterJob) error {
+	var errs []error
+	for _, job := range jobs {
+		if _, err := job.Results(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d bulk writes failed: %w", len(errs), errors.Join(errs...))
+	}
+	return nil
+}
+
+func (s *Storage) SaveSession(ctx context.Context, session *SessionDocument) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.SaveSession")
+	defer tracing.End(span, &err)
+	_, err = s.FirestoreClient.Collection("board-game-sessions").Doc(session.ID).Set(ctx, session)
+	return err
+}
+
+func (s *Storage) GetSession(ctx context.Context, sessionId string) (_ *SessionDocument, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.GetSession")
+	defer tracing.End(span, &err)
+	snapshot, err := s.GetDocument(ctx, "board-game-sessions", sessionId)
+	if err != nil {
+		return nil, err
+	}
+	var session SessionDocument
+	if err := snapshot.DataTo(&session); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+	return &session, nil
+}
+
+// ListSessions returns every session, most recent first.
+func (s *Storage) ListSessions(ctx context.Context) (_ []SessionDocument, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ListSessions")
+	defer tracing.End(span, &err)
+	snapshots, err := s.FirestoreClient.Collection("board-game-sessions").
+		OrderBy("date", firestore.Desc).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := make([]SessionDocument, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var session SessionDocument
+		if err := snapshot.DataTo(&session); err != nil {
+			return nil, fmt.Errorf("failed to decode session %s: %w", snapshot.Ref.ID, err)
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+func (s *Storage) UpdateSession(ctx context.Context, sessionId string, updates []firestore.Update) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.UpdateSession")
+	defer tracing.End(span, &err)
+	_, err = s.FirestoreClient.Collection("board-game-sessions").Doc(sessionId).Update(ctx, updates)
+	return err
+}
+
+func (s *Storage) UpdateGameScorecard(ctx context.Context, scorecardId string, updates []firestore.Update) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.UpdateGameScorecard")
+	defer tracing.End(span, &err)
+	_, err = s.FirestoreClient.Collection("board-game-scorecards").Doc(scorecardId).Update(ctx, updates)
+	return err
+}
+
+// SetScorecardSession links a scorecard to a session, or unlinks it when sessionId is empty.
+func (s *Storage) SetScorecardSession(ctx context.Context, scorecardId, sessionId string) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.SetScorecardSession")
+	defer tracing.End(span, &err)
+	return setSessionField(ctx, s.FirestoreClient.Collection("board-game-scorecards").Doc(scorecardId), sessionId)
+}
+
+// SetImageUploadSession records the session an image was uploaded for, so the
+// scorecard later created from it can be linked to the same session.
+func (s *Storage) SetImageUploadSession(ctx context.Context, imageUploadId, sessionId string) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.SetImageUploadSession")
+	defer tracing.End(span, &err)
+	return setSessionField(ctx, s.FirestoreClient.Collection("board-game-image-uploads").Doc(imageUploadId), sessionId)
+}
+
+func setSessionField(ctx context.Context, reference *firestore.DocumentRef, sessionId string) error {
+	var value any = sessionId
+	if sessionId == "" {
+		value = firestore.Delete
+	}
+	_, err := reference.Update(ctx, []firestore.Update{{Path: "session_id", Value: value}})
 	return err
 }
+
+// GetImageUploadSessionID returns the session an image upload was linked to, or
+// an empty string if it was not linked.
+func (s *Storage) GetImageUploadSessionID(ctx context.Context, imageUploadId string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.GetImageUploadSessionID")
+	defer tracing.End(span, &err)
+	snapshot, err := s.GetDocument(ctx, "board-game-image-uploads", imageUploadId)
+	if err != nil {
+		return "", err
+	}
+	return sessionIdAt(snapshot), nil
+}
+
+func (s *Storage) GetScorecardSessionID(ctx context.Context, scorecardId string) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.GetScorecardSessionID")
+	defer tracing.End(span, &err)
+	snapshot, err := s.GetDocument(ctx, "board-game-scorecards", scorecardId)
+	if err != nil {
+		return "", err
+	}
+	return sessionIdAt(snapshot), nil
+}
+
+// sessionIdAt reads the session_id field written by setSessionField, which is
+// only present on documents linked to a session.
+func sessionIdAt(snapshot *firestore.DocumentSnapshot) string {
+	value, err := snapshot.DataAt("session_id")
+	if err != nil {
+		return ""
+	}
+	sessionId, _ := value.(string)
+	return sessionId
+}
+
+func (s *Storage) ListGameScorecardsBySession(ctx context.Context, sessionId string) (_ []documents.ScorecardDocumentCreate, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ListGameScorecardsBySession")
+	defer tracing.End(span, &err)
+	snapshots, err := s.FirestoreClient.Collection("board-game-scorecards").
+		Where("session_id", "==", sessionId).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list session scorecards: %w", err)
+	}
+
+	scorecards := make([]documents.ScorecardDocumentCreate, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var scorecard documents.ScorecardDocumentCreate
+		if err := snapshot.DataTo(&scorecard); err != nil {
+			return nil, fmt.Errorf("failed to decode scorecard %s: %w", snapshot.Ref.ID, err)
+		}
+		scorecards = append(scorecards, scorecard)
+	}
+	sort.SliceStable(scorecards, func(i, j int) bool { return scorecards[i].CreatedAt.Before(scorecards[j].CreatedAt) })
+	return scorecards, nil
+}
+
+func (s *Storage) SaveLocation(ctx context.Context, location *LocationDocument) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.SaveLocation")
+	defer tracing.End(span, &err)
+	_, err = s.FirestoreClient.Collection("board-game-locations").Doc(location.ID).Set(ctx, location)
+	return err
+}
+
+func (s *Storage) GetLocation(ctx context.Context, locationId string) (_ *LocationDocument, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.GetLocation")
+	defer tracing.End(span, &err)
+	snapshot, err := s.GetDocument(ctx, "board-game-locations", locationId)
+	if err != nil {
+		return nil, err
+	}
+	var location LocationDocument
+	if err := snapshot.DataTo(&location); err != nil {
+		return nil, fmt.Errorf("failed to decode location: %w", err)
+	}
+	return &location, nil
+}
+
+func (s *Storage) ListLocations(ctx context.Context) (_ []LocationDocument, err error) {
+	ctx, span := tracing.Start(ctx, "firestore.ListLocations")
+	defer tracing.End(span, &err)
+	snapshots, err := s.FirestoreClient.Collection("board-game-locations").
+		OrderBy("name", firestore.Asc).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list locations: %w", err)
+	}
+
+	locations := make([]LocationDocument, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var location LocationDocument
+		if err := snapshot.DataTo(&location); err != nil {
+			return nil, fmt.Errorf("failed to decode location %s: %w", snapshot.Ref.ID, err)
+		}
+		locations = append(locations, location)
+	}
+	return locations, nil
+}
+
+func (s *Storage) UpdateLocation(ctx context.Context, locationId string, updates []firestore.Update) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.UpdateLocation")
+	defer tracing.End(span, &err)
+	_, err = s.FirestoreClient.Collection("board-game-locations").Doc(locationId).Update(ctx, updates)
+	return err
+}
+
+// MergeLocation saves target and deletes the location with id sourceId in one
+// transaction, so neither write is applied without the other.
+func (s *Storage) MergeLocation(ctx context.Context, target *LocationDocument, sourceId string) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.MergeLocation")
+	defer tracing.End(span, &err)
+	collection := s.FirestoreClient.Collection("board-game-locations")
+	return s.FirestoreClient.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		if err := tx.Set(collection.Doc(target.ID), target); err != nil {
+			return err
+		}
+		// a source deleted since it was read fails the merge rather than being recreated as an alias
+		return tx.Delete(collection.Doc(sourceId), firestore.Exists)
+	})
+}
+
+// SetGameScorecardLocations rewrites the location of every listed scorecard.
+func (s *Storage) SetGameScorecardLocations(ctx context.Context, scorecardIds []string, location string) (err error) {
+	ctx, span := tracing.Start(ctx, "firestore.SetGameScorecardLocations")
+	defer tracing.End(span, &err)
+	collection := s.FirestoreClient.Collection("board-game-scorecards")
+	writer := s.FirestoreClient.BulkWriter(ctx)
+	var jobs []*firestore.BulkWriterJob
+	for _, scorecardId := range scorecardIds {
+		job, err := writer.Update(collection.Doc(scorecardId), []firestore.Update{{Path: "location", Value: location}})
+		if err != nil {
+			writer.End()
+			return fmt.Errorf("failed to queue scorecard location update: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	writer.End()
+
+	return bulkWriterJobsError(jobs)
+}
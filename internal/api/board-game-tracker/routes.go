@@ -9,25 +9,68 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
-	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/config"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/deprecation"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/idempotency"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit"
 )
 
-func RegisterRoutes(cfg *config.Config, rg *gin.RouterGroup, service *ScoreService) {
+func RegisterRoutes(rg *gin.RouterGroup, service *ScoreService, authenticator *auth.Authenticator, limiter *ratelimit.Limiter, idempotencyStore *idempotency.Store, v1Sunset deprecation.Notice) {
 	// setup different route groups for various auth levels
-	boardGameTrackerGroup := rg.Group("/board-game-tracker")                                                           // public
-	boardGameTrackerAuthNGroup := boardGameTrackerGroup.Group("/", auth.RequireAuth(nil))                              // authN
-	boardGameTrackerAuthZAdminGroup := boardGameTrackerGroup.Group("/", auth.RequireAuth(config.GetAdminEmails(*cfg))) // authZ
+	boardGameTrackerGroup := rg.Group("/board-game-tracker")                                                                                    // public
+	boardGameTrackerAuthNGroup := boardGameTrackerGroup.Group("/", authenticator.RequireRole(auth.RoleViewer), limiter.Limit())                 // authN
+	boardGameTrackerAuthZContributorGroup := boardGameTrackerGroup.Group("/", authenticator.RequireRole(auth.RoleContributor), limiter.Limit()) // authZ
+	boardGameTrackerAuthZAdminGroup := boardGameTrackerGroup.Group("/", authenticator.RequireRole(auth.RoleAdmin), limiter.Limit())             // authZ
 
 	// mount authN groups
 	// TODO: delete dummy route once actual routes are in place
 	boardGameTrackerAuthNGroup.GET("/dummy", func(c *gin.Context) {
 		c.JSON(http.StatusOK, gin.H{"message": "found a dummy", "dummy": "you"})
 	})
+	boardGameTrackerAuthNGroup.GET("/ratings", HandleGetRatings(service))
+	boardGameTrackerAuthNGroup.GET("/ratings/:player/history", HandleGetRatingHistory(service))
+	boardGameTrackerAuthNGroup.GET("/stats/head-to-head", HandleGetHeadToHead(service))
+	boardGameTrackerAuthNGroup.GET("/sessions", HandleListSessions(service))
+	boardGameTrackerAuthNGroup.GET("/sessions/:sessionId", HandleGetSession(service))
+	boardGameTrackerAuthNGroup.GET("/sessions/:sessionId/summary", HandleGetSessionSummary(service))
+	boardGameTrackerAuthNGroup.GET("/locations", HandleListLocations(service))
+	boardGameTrackerAuthNGroup.GET("/locations/:locationId", HandleGetLocation(service))
+	boardGameTrackerAuthNGroup.GET("/locations/:locationId/stats", HandleGetLocationStats(service))
+
+	// mount contributor routes, where updates and deletes are limited to the contributor's own scorecards
+	// creates replay their first response for a repeated Idempotency-Key, so retries spend no llm budget
+	// each of these has a v2 successor, which clients are pointed to with deprecation headers
+	boardGameTrackerAuthZContributorGroup.POST("/parse-score-card/:game", v1Sunset.Middleware("/api/v2/parses"), idempotencyStore.Middleware(), HandleParseScoreCard(service)) // TODO: remove after UI release of OC-50
+	boardGameTrackerAuthZContributorGroup.PATCH("/update-score-card/:documentId", v1Sunset.Middleware("/api/v2/scorecards/:documentId"), HandleUpdateScoreCard(service))
+	boardGameTrackerAuthZContributorGroup.POST("/parse-score-card-from-image/:game", v1Sunset.Middleware("/api/v2/parses"), idempotencyStore.Middleware(), HandleParseScoreCardFromImage(service))
+	boardGameTrackerAuthZContributorGroup.POST("/create-score-card/", v1Sunset.Middleware("/api/v2/scorecards"), idempotencyStore.Middleware(), HandleCreateScoreCard(service))
+	boardGameTrackerAuthZContributorGroup.DELETE("/delete-score-card/:documentId", v1Sunset.Middleware("/api/v2/scorecards/:documentId"), HandleDeleteScoreCard(service))
 
 	// mount admin routes
-	boardGameTrackerAuthZAdminGroup.POST("/parse-score-card/:game", HandleParseScoreCard(service)) // TODO: deprecate after UI release of OC-50
-	boardGameTrackerAuthZAdminGroup.PATCH("/update-score-card/:documentId", HandleUpdateScoreCard(service))
-	boardGameTrackerAuthZAdminGroup.POST("/parse-score-card-from-image/:game", HandleParseScoreCardFromImage(service))
-	boardGameTrackerAuthZAdminGroup.POST("/create-score-card/", HandleCreateScoreCard(service))
-	boardGameTrackerAuthZAdminGroup.DELETE("/delete-score-card/:documentId", HandleDeleteScoreCard(service))
+	boardGameTrackerAuthZAdminGroup.POST("/ratings/recompute", HandleRecomputeRatings(service))
+	boardGameTrackerAuthZAdminGroup.POST("/sessions", HandleCreateSession(service))
+	boardGameTrackerAuthZAdminGroup.PATCH("/sessions/:sessionId", HandleUpdateSession(service))
+	boardGameTrackerAuthZAdminGroup.DELETE("/sessions/:sessionId", HandleDeleteSession(service))
+	boardGameTrackerAuthZAdminGroup.PUT("/sessions/:sessionId/scorecards/:documentId", HandleLinkSessionScoreCard(service))
+	boardGameTrackerAuthZAdminGroup.DELETE("/sessions/:sessionId/scorecards/:documentId", HandleLinkSessionScoreCard(service))
+	boardGameTrackerAuthZAdminGroup.POST("/locations", HandleCreateLocation(service))
+	boardGameTrackerAuthZAdminGroup.PATCH("/locations/:locationId", HandleUpdateLocation(service))
+	boardGameTrackerAuthZAdminGroup.DELETE("/locations/:locationId", HandleDeleteLocation(service))
+	boardGameTrackerAuthZAdminGroup.POST("/locations/:locationId/merge", HandleMergeLocations(service))
+}
+
+// RegisterV2Routes mounts the resource-oriented scorecard API, which shares
+// its handlers' steps with the v1 routes above.
+func RegisterV2Routes(rg *gin.RouterGroup, service *ScoreService, authenticator *auth.Authenticator, limiter *ratelimit.Limiter, idempotencyStore *idempotency.Store) {
+	viewerGroup := rg.Group("/", authenticator.RequireRole(auth.RoleViewer), limiter.Limit())
+	contributorGroup := rg.Group("/", authenticator.RequireRole(auth.RoleContributor), limiter.Limit())
+
+	viewerGroup.GET("/scorecards/:documentId", HandleV2GetScorecard(service))
+
+	// creates replay their first response for a repeated Idempotency-Key, and
+	// updates and deletes are limited to the contributor's own scorecards
+	contributorGroup.POST("/scorecards", idempotencyStore.Middleware(), HandleV2CreateScorecard(service))
+	contributorGroup.PATCH("/scorecards/:documentId", HandleV2UpdateScorecard(service))
+	contributorGroup.DELETE("/scorecards/:documentId", HandleV2DeleteScorecard(service))
+	contributorGroup.POST("/uploads", idempotencyStore.Middleware(), HandleV2CreateUpload(service))
+	contributorGroup.POST("/parses", idempotencyStore.Middleware(), HandleV2CreateParse(service))
 }
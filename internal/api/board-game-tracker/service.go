@@ -8,26 +8,64 @@ package boardgametracker
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"math"
+	"net/http"
+	"slices"
+	"sort"
 	"strings"
+	"sync"
 	"time"
+	"unicode/utf8"
 
+	"cloud.google.com/go/firestore"
 	"github.com/google/uuid"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/lifecycle"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/metrics"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/tracing"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/validation"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/elo"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/gemini"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/helpers"
 	"github.com/owen-crook/board-game-tracker-go-common/pkg/documents"
 	"github.com/owen-crook/board-game-tracker-go-common/pkg/gamedata"
 	"github.com/owen-crook/board-game-tracker-go-common/pkg/games"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const ratingScopeOverall = "overall"
+
+const (
+	ratingsLease = "ratings"
+	// ratingsLeaseTTL bounds how long a replay that died keeps others waiting
+	ratingsLeaseTTL   = 5 * time.Minute
+	ratingsLeaseRetry = 2 * time.Second
 )
 
 type ScoreService struct {
 	Repository   *Storage
 	GeminiClient *gemini.Client
+	// LLMBudget limits how many images each user can have read per day.
+	// When nil there is no limit.
+	LLMBudget *ratelimit.LLMBudget
+	// Jobs runs work that should outlive the request, such as rating refreshes.
+	// When nil that work runs inline.
+	Jobs *lifecycle.Manager
+
+	// ratingsMu queues rating replays on this instance, while the ratings
+	// lease keeps replays on different instances from interleaving
+	ratingsMu sync.Mutex
 }
 
-func GetTextFromLLM(ctx context.Context, service *ScoreService, game games.Game, image []byte) (string, error) {
+func GetTextFromLLM(ctx context.Context, service *ScoreService, game games.Game, image []byte) (_ string, err error) {
+	ctx, span := tracing.Start(ctx, "llm.GetTextFromLLM", attribute.String("game", string(game)), attribute.Int("image.bytes", len(image)))
+	defer tracing.End(span, &err)
+
 	// grab standard prompt elements from critical functions to support
 	// dynamic generate of the prompt
 	categories, err := gamedata.GetScoringCategoriesByGame(game)
@@ -68,12 +106,16 @@ func GetTextFromLLM(ctx context.Context, service *ScoreService, game games.Game,
 
 	text, err := service.GeminiClient.GenerateFromTextAndImage(ctx, prompt, image)
 	if err != nil {
+		metrics.ObserveParse(string(game), metrics.ParseFailed, "llm_error")
 		return "", fmt.Errorf("failed to generate text from image: %w", err)
 	}
 	return text, nil
 }
 
-func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetadataId, game, text string, submittedDate time.Time, service *ScoreService) (*documents.ScorecardDocumentRaw, error) {
+func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetadataId, game, text string, submittedDate time.Time, service *ScoreService) (_ *documents.ScorecardDocumentRaw, err error) {
+	ctx, span := tracing.Start(ctx, "scorecard.Parse", attribute.String("game", game), attribute.String("image_upload_id", imageUploadMetadataId))
+	defer tracing.End(span, &err)
+
 	// initialize final vars
 	var finalDate time.Time
 	var parsedDate time.Time
@@ -99,14 +141,16 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 
 	if jsonStart == -1 || jsonEnd == -1 || jsonEnd < jsonStart {
 		// TODO: return partially completed scorecard instead of error
+		metrics.ObserveParse(game, metrics.ParseFailed, "no_json")
 		return nil, fmt.Errorf("no valid JSON object found within LLM response")
 	}
 
 	likelyJsonString := text[jsonStart : jsonEnd+1]
 	var parsedJson map[string]interface{}
-	err := json.Unmarshal([]byte(likelyJsonString), &parsedJson)
+	err = json.Unmarshal([]byte(likelyJsonString), &parsedJson)
 	if err != nil {
 		// TODO: return partially completed scorecard instead of error
+		metrics.ObserveParse(game, metrics.ParseFailed, "invalid_json")
 		return nil, fmt.Errorf("unable to parse potential JSON string until actual JSON")
 	}
 
@@ -120,10 +164,10 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 		if parsedDateVal != nil {
 			parsedDateStr, ok := parsedDateVal.(string)
 			if ok {
-				log.Printf("found datestring %s", parsedDateStr)
+				logging.FromContext(ctx).Debug("Found date string", "date", parsedDateStr)
 				parsedDate, err = helpers.ParseFlexibleDate(parsedDateStr)
 				if err != nil {
-					log.Printf("unable to parse string to date for %s", parsedDateStr)
+					logging.FromContext(ctx).Warn("Unable to parse date string", "date", parsedDateStr)
 				} else {
 					finalDate = helpers.TimeAsCalendarDateOnly(parsedDate)
 				}
@@ -136,7 +180,7 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 		if locationVal != nil {
 			locationStr, ok := locationVal.(string)
 			if ok {
-				location = locationStr
+				location = service.ResolveLocation(ctx, locationStr)
 			}
 		}
 	}
@@ -144,6 +188,7 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 	// determine expected columns on the players scores
 	categories, err := gamedata.GetScoringCategoriesByGame(games.Game(game))
 	if err != nil {
+		metrics.ObserveParse(game, metrics.ParseFailed, "unknown_game")
 		return nil, err
 	}
 	var expectedKeys []string
@@ -182,7 +227,7 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 							if key == "name" { // name should be a string
 								valueStr, ok := value.(string)
 								if !ok {
-									log.Printf("name is not string, using unknown")
+									logging.FromContext(ctx).Warn("Player name is not a string, using unknown")
 									playerClean[key] = "unknown"
 								} else {
 									playerClean[key] = strings.ToLower(valueStr)
@@ -190,13 +235,13 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 							} else { // everything else should be an integer
 								floatVal, ok := value.(float64)
 								if !ok {
-									log.Printf("score for key %s is not int, using 0", key)
+									logging.FromContext(ctx).Warn("Score is not an int, using 0", "key", key)
 									playerClean[key] = 0
 								} else {
 									if float64(int(floatVal)) == floatVal {
 										playerClean[key] = int(floatVal)
 									} else {
-										log.Printf("score for key %s is float, not int, rounding", key)
+										logging.FromContext(ctx).Debug("Score is a float, rounding", "key", key)
 										playerClean[key] = int(math.Round(floatVal))
 									}
 								}
@@ -215,11 +260,11 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 
 					if len(extra) > 0 {
 						playerIsValid = false
-						log.Printf("found the following extra keys: %v", extra)
+						logging.FromContext(ctx).Warn("Scorecard has extra keys", "keys", extra)
 					}
 					if len(missing) > 0 {
 						playerIsValid = false
-						log.Printf("found the following missing keys: %v", missing)
+						logging.FromContext(ctx).Warn("Scorecard is missing keys", "keys", missing)
 					}
 
 					if playerIsValid {
@@ -236,6 +281,16 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 		}
 	}
 
+	// track why scorecards come back incomplete so extraction can be tuned per game
+	switch {
+	case !foundPlayerScores:
+		metrics.ObserveParse(game, metrics.ParseIncomplete, "missing_players")
+	case !allItemsInPlayerScoresValid:
+		metrics.ObserveParse(game, metrics.ParseIncomplete, "invalid_player_scores")
+	default:
+		metrics.ObserveParse(game, metrics.ParseComplete, "")
+	}
+
 	return &documents.ScorecardDocumentRaw{
 		ImageUploadMetadataID: imageUploadMetadataId,
 		Game:                  game,
@@ -246,66 +301,1407 @@ func GenerateGameScorecardDocumentFromText(ctx context.Context, imageUploadMetad
 	}, nil
 }
 
-func DeleteGameScorecardAndMetadta(ctx context.Context, service *ScoreService, scorecardId string) error {
-	// validate the scorecardId
-	scorecardExists, err := service.Repository.CheckDocumentExists(ctx, "board-game-scorecards", scorecardId)
+// Actor is the user a service call is made for. Contributors may only change
+// what they created, admins may change anything.
+type Actor struct {
+	Email string
+	Role  auth.Role
+}
+
+// canEdit reports whether the actor may change something created by createdBy.
+func (a Actor) canEdit(createdBy *string) bool {
+	if a.Role == auth.RoleAdmin {
+		return true
+	}
+	return createdBy != nil && strings.EqualFold(*createdBy, a.Email)
+}
+
+// RequireSession returns a not found error when a non-empty session id does
+// not refer to an existing session.
+func (s *ScoreService) RequireSession(ctx context.Context, sessionId string) error {
+	if sessionId == "" {
+		return nil
+	}
+	exists, err := s.Repository.CheckDocumentExists(ctx, "board-game-sessions", sessionId)
 	if err != nil {
-		log.Printf("Error checking document existence: %v", err)
-		return err
+		return apierror.Internal("failed to check session existence", err)
 	}
-	if !scorecardExists {
-		return fmt.Errorf("document with ID %s not found", scorecardId)
+	if !exists {
+		return apierror.NotFound("session_not_found", fmt.Sprintf("Session with ID %s not found", sessionId))
+	}
+	return nil
+}
+
+// UploadImage stores a scorecard image in GCS along with its upload document,
+// linked to sessionId when one is given. Uploads are kept whether or not they
+// are ever parsed.
+func (s *ScoreService) UploadImage(ctx context.Context, actor Actor, image []byte, sessionId string) (*documents.ImageUploadCreate, error) {
+	if err := s.RequireSession(ctx, sessionId); err != nil {
+		return nil, err
 	}
 
-	// fetch the scorecard to grab image upload id
-	scorecard, err := service.Repository.GetDocument(ctx, "board-game-scorecards", scorecardId)
+	// the content type is sniffed from the first 512 bytes
+	bucket, path, err := s.Repository.SaveImage(ctx, image, http.DetectContentType(image))
 	if err != nil {
-		log.Printf("Error fetching scorecard: %v", err)
-		return err
+		return nil, apierror.Internal("failed to save image", err)
+	}
+
+	upload := documents.ImageUploadCreate{
+		ID:        uuid.New().String(),
+		Bucket:    bucket,
+		Path:      path,
+		CreatedBy: &actor.Email,
+		CreatedAt: time.Now().In(time.UTC),
+	}
+	if err := s.Repository.SaveImageUpload(ctx, &upload); err != nil {
+		return nil, apierror.Internal("failed to save image upload", err)
+	}
+
+	// remember the session so a scorecard created from this upload is linked to it
+	if sessionId != "" {
+		if err := s.Repository.SetImageUploadSession(ctx, upload.ID, sessionId); err != nil {
+			return nil, apierror.Internal("failed to link image upload to session", err)
+		}
+	}
+	return &upload, nil
+}
+
+// ParseFromImage uploads a scorecard image and reads it with the LLM,
+// returning the unsaved scorecard for review. The upload is kept even when
+// the image cannot be read.
+func (s *ScoreService) ParseFromImage(ctx context.Context, actor Actor, game string, image []byte, date time.Time, sessionId string) (*documents.ScorecardDocumentRaw, error) {
+	if !games.IsSupportedGame(games.Game(game)) {
+		return nil, apierror.Validation("unsupported_game", fmt.Sprintf("Unsupported game: %s", game))
+	}
+	upload, err := s.UploadImage(ctx, actor, image, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	return s.parseImage(ctx, actor, upload, image, game, date)
+}
+
+// ParseUpload reads an earlier upload with the LLM, returning the unsaved
+// scorecard for review. Contributors may only parse their own uploads.
+func (s *ScoreService) ParseUpload(ctx context.Context, actor Actor, uploadId, game string, date time.Time) (*documents.ScorecardDocumentRaw, error) {
+	if !games.IsSupportedGame(games.Game(game)) {
+		return nil, apierror.Validation("unsupported_game", fmt.Sprintf("Unsupported game: %s", game))
+	}
+	exists, err := s.Repository.CheckDocumentExists(ctx, "board-game-image-uploads", uploadId)
+	if err != nil {
+		return nil, apierror.Internal("failed to check upload existence", err)
+	}
+	if !exists {
+		return nil, apierror.NotFound("upload_not_found", fmt.Sprintf("Upload with ID %s not found", uploadId))
 	}
-	var scorecardData documents.ScorecardDocumentCreate
-	if err := scorecard.DataTo(&scorecardData); err != nil {
-		log.Printf("Error converting scorecard data: %v", err)
+	upload, err := s.Repository.GetImageUpload(ctx, uploadId)
+	if err != nil {
+		return nil, apierror.Internal("failed to fetch upload", err)
+	}
+	if !actor.canEdit(upload.CreatedBy) {
+		return nil, apierror.Forbidden("not_upload_owner", "only the creator of an upload or an admin can parse it")
+	}
+
+	image, err := s.Repository.ReadImage(ctx, upload.Path)
+	if err != nil {
+		return nil, apierror.Internal("failed to read uploaded image", err)
+	}
+	return s.parseImage(ctx, actor, upload, image, game, date)
+}
+
+// parseImage reads the scorecard in an uploaded image, keeping the LLM's text
+// on the upload. Each read spends one call of the actor's daily budget.
+func (s *ScoreService) parseImage(ctx context.Context, actor Actor, upload *documents.ImageUploadCreate, image []byte, game string, date time.Time) (*documents.ScorecardDocumentRaw, error) {
+	if err := s.LLMBudget.Spend(ctx, actor.Email); err != nil {
+		return nil, err
+	}
+
+	// send the request to Gemini
+	text, err := GetTextFromLLM(ctx, s, games.Game(game), image)
+	if err != nil {
+		return nil, apierror.Upstream("llm_failed", "failed to read the scorecard image", err)
+	}
+
+	// the text is only kept for debugging parses, so losing it does not fail the parse
+	if err := s.Repository.SetImageUploadParsedContent(ctx, upload.ID, text); err != nil {
+		logging.FromContext(ctx).Error("Error saving parsed content", "image_upload_id", upload.ID, "error", err)
+	}
+	upload.LlmParsedContent = &text
+
+	// parse the content from the string into known struct
+	document, err := GenerateGameScorecardDocumentFromText(ctx, upload.ID, game, text, date, s)
+	if err != nil {
+		return nil, apierror.Upstream("scorecard_unreadable", "could not extract a scorecard from the image", err)
+	}
+	return document, nil
+}
+
+// CreateScorecard saves a new scorecard for actor, linked to input's session
+// or else the session its image was uploaded for, and replays ratings from
+// its date. input is checked again here, as scorecards read by the LLM are
+// saved without ever being bound from a request.
+func (s *ScoreService) CreateScorecard(ctx context.Context, actor Actor, input ScorecardRequest) (*documents.ScorecardDocumentCreate, error) {
+	if err := checkScorecardRequest(input); err != nil {
+		return nil, err
+	}
+
+	sessionId := input.SessionID
+	if err := s.RequireSession(ctx, sessionId); err != nil {
+		return nil, err
+	}
+
+	// deleting the scorecard deletes its upload, so it must be actor's to link
+	if input.ImageUploadMetadataID != "" {
+		var upload *documents.ImageUploadCreate
+		exists, err := s.Repository.CheckDocumentExists(ctx, "board-game-image-uploads", input.ImageUploadMetadataID)
+		if err != nil {
+			return nil, apierror.Internal("failed to check upload existence", err)
+		}
+		if exists {
+			upload, err = s.Repository.GetImageUpload(ctx, input.ImageUploadMetadataID)
+			if err != nil {
+				return nil, apierror.Internal("failed to fetch upload", err)
+			}
+		}
+		if err := checkScorecardUpload(actor, input.ImageUploadMetadataID, upload); err != nil {
+			return nil, err
+		}
+		if sessionId == "" {
+			sessionId = s.sessionIdFromImageUpload(ctx, input.ImageUploadMetadataID)
+		}
+	}
+
+	scorecard := documents.ScorecardDocumentCreate{
+		ID:                    uuid.New().String(),
+		ImageUploadMetadataID: input.ImageUploadMetadataID,
+		Game:                  input.Game,
+		Date:                  input.Date,
+		PlayerScores:          &input.PlayerScores,
+		Location:              input.Location,
+		IsCompleted:           input.IsCompleted,
+		CreatedBy:             &actor.Email,
+		CreatedAt:             time.Now().In(time.UTC),
+	}
+	if err := s.Repository.SaveGameScorecardDocument(ctx, &scorecard); err != nil {
+		return nil, apierror.Internal("failed to save scorecard", err)
+	}
+
+	// link the scorecard to its session
+	if sessionId != "" {
+		if err := s.Repository.SetScorecardSession(ctx, scorecard.ID, sessionId); err != nil {
+			logging.FromContext(ctx).Error("Scorecard session error", "error", err)
+		}
+	}
+
+	// replay ratings from the new scorecard's date onwards
+	s.refreshRatingsFrom(ctx, scorecard.Date)
+
+	return &scorecard, nil
+}
+
+// checkScorecardRequest applies the checks ScorecardRequest's binding tags make.
+func checkScorecardRequest(input ScorecardRequest) error {
+	if !games.IsSupportedGame(games.Game(input.Game)) {
+		return validation.Field("game", fmt.Errorf("%s is not a supported game", input.Game))
+	}
+	if err := validation.CheckGameDate(input.Date); err != nil {
+		return validation.Field("date", err)
+	}
+	if input.Location != nil && utf8.RuneCountInString(*input.Location) > 200 {
+		return validation.Field("location", errors.New("must be at most 200 characters"))
+	}
+	if len(input.PlayerScores) == 0 {
+		return validation.Field("player_scores", errors.New("must not be empty"))
+	}
+	if err := validation.CheckPlayerScores(input.Game, input.PlayerScores); err != nil {
+		return validation.Field("player_scores", err)
+	}
+	return nil
+}
+
+// checkScorecardUpload reports whether actor may create a scorecard from the
+// upload with the given id, where upload is nil when there is no such upload.
+func checkScorecardUpload(actor Actor, uploadId string, upload *documents.ImageUploadCreate) error {
+	if upload == nil {
+		return apierror.Unprocessable("upload_not_found", fmt.Sprintf("Upload with ID %s not found", uploadId))
+	}
+	if !actor.canEdit(upload.CreatedBy) {
+		return apierror.Forbidden("not_upload_owner", "only the creator of an upload or an admin can create a scorecard from it")
+	}
+	return nil
+}
+
+// GetScorecard returns a scorecard, or a not found error.
+func (s *ScoreService) GetScorecard(ctx context.Context, documentId string) (*documents.ScorecardDocumentCreate, error) {
+	exists, err := s.Repository.CheckDocumentExists(ctx, "board-game-scorecards", documentId)
+	if err != nil {
+		return nil, apierror.Internal("failed to check document existence", err)
+	}
+	if !exists {
+		return nil, apierror.NotFound("scorecard_not_found", fmt.Sprintf("Document with ID %s not found", documentId))
+	}
+	scorecard, err := s.Repository.GetGameScorecardDocument(ctx, documentId)
+	if err != nil {
+		return nil, apierror.Internal("failed to fetch scorecard", err)
+	}
+	return scorecard, nil
+}
+
+// UpdateScorecard applies update to a scorecard, replays ratings from the
+// earlier of its old and new dates and returns the updated scorecard.
+// Contributors may only update their own scorecards.
+func (s *ScoreService) UpdateScorecard(ctx context.Context, actor Actor, documentId string, update ScorecardUpdate) (*documents.ScorecardDocumentCreate, error) {
+	// keep the current scorecard so ratings can be replayed from its original date
+	existing, err := s.GetScorecard(ctx, documentId)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.canEdit(existing.CreatedBy) {
+		return nil, apierror.Forbidden("not_scorecard_owner", "only the creator of a scorecard or an admin can change it")
+	}
+
+	// the player scores must fit the game the scorecard ends up with
+	if update.Game != nil || update.PlayerScores != nil {
+		game, playerScores := existing.Game, existing.PlayerScores
+		if update.Game != nil {
+			game = *update.Game
+		}
+		if update.PlayerScores != nil {
+			playerScores = update.PlayerScores
+		}
+		if playerScores != nil {
+			if err := validation.CheckPlayerScores(game, *playerScores); err != nil {
+				return nil, validation.Field("player_scores", err)
+			}
+		}
+	}
+
+	// handle updates
+	var updates []firestore.Update
+	if update.Game != nil {
+		updates = append(updates, firestore.Update{Path: "game", Value: *update.Game})
+	}
+	if update.Date != nil {
+		*update.Date = helpers.TimeAsCalendarDateOnly(*update.Date)
+		updates = append(updates, firestore.Update{Path: "date", Value: *update.Date})
+	}
+	if update.IsCompleted != nil {
+		updates = append(updates, firestore.Update{Path: "is_completed", Value: *update.IsCompleted})
+	}
+	if update.Location != nil {
+		updates = append(updates, firestore.Update{Path: "location", Value: *update.Location})
+	}
+	if update.PlayerScores != nil {
+		updates = append(updates, firestore.Update{Path: "player_scores", Value: *update.PlayerScores})
+	}
+	if len(updates) == 0 {
+		return nil, apierror.Validation("no_updates", "no updates provided")
+	}
+
+	// add updated by and updated at
+	updates = append(updates, firestore.Update{Path: "updated_by", Value: actor.Email})
+	updates = append(updates, firestore.Update{Path: "updated_at", Value: time.Now().In(time.UTC)})
+
+	if err := s.Repository.UpdateGameScorecard(ctx, documentId, updates); err != nil {
+		return nil, apierror.Internal("failed to update scorecard", err)
+	}
+
+	// replay ratings from whichever of the old and new dates is earlier
+	ratingsFrom := existing.Date
+	if update.Date != nil && update.Date.Before(ratingsFrom) {
+		ratingsFrom = *update.Date
+	}
+	s.refreshRatingsFrom(ctx, ratingsFrom)
+
+	updated, err := s.Repository.GetGameScorecardDocument(ctx, documentId)
+	if err != nil {
+		return nil, apierror.Internal("failed to fetch scorecard", err)
+	}
+	return updated, nil
+}
+
+// DeleteScorecard deletes a scorecard along with its image and upload.
+// Contributors may only delete their own scorecards.
+func (s *ScoreService) DeleteScorecard(ctx context.Context, actor Actor, documentId string) error {
+	existing, err := s.GetScorecard(ctx, documentId)
+	if err != nil {
 		return err
 	}
+	if !actor.canEdit(existing.CreatedBy) {
+		return apierror.Forbidden("not_scorecard_owner", "only the creator of a scorecard or an admin can change it")
+	}
+	return s.DeleteGameScorecardAndMetadta(ctx, existing)
+}
+
+// DeleteGameScorecardAndMetadta deletes a scorecard, then replays ratings
+// without it.
+func (s *ScoreService) DeleteGameScorecardAndMetadta(ctx context.Context, scorecard *documents.ScorecardDocumentCreate) error {
+	// scorecards entered by hand have no image to clean up
+	if scorecard.ImageUploadMetadataID != "" {
+		if err := s.deleteImageUpload(ctx, scorecard.ImageUploadMetadataID); err != nil {
+			return err
+		}
+	}
 
-	// fetch the image upload document to grab the image path
-	if scorecardData.ImageUploadMetadataID == "" {
-		return fmt.Errorf("image upload metadata ID is empty")
+	if err := s.Repository.DeleteDocument(ctx, "board-game-scorecards", scorecard.ID); err != nil {
+		return apierror.Internal("failed to delete scorecard", err)
 	}
-	imageUploadMetadata, err := service.Repository.GetDocument(ctx, "board-game-image-uploads", scorecardData.ImageUploadMetadataID)
+
+	// replay ratings without the deleted scorecard
+	s.refreshRatingsFrom(ctx, scorecard.Date)
+
+	return nil
+}
+
+// deleteImageUpload deletes an uploaded image from the bucket along with its
+// upload document.
+func (s *ScoreService) deleteImageUpload(ctx context.Context, imageUploadId string) error {
+	upload, err := s.Repository.GetImageUpload(ctx, imageUploadId)
+	if err != nil {
+		return apierror.Internal("failed to fetch image upload", err)
+	}
+
+	// delete the image from bucket
+	if upload.Bucket != "" && upload.Path != "" {
+		if err := s.Repository.DeleteImage(ctx, upload.Path); err != nil {
+			return apierror.Internal("failed to delete image", err)
+		}
+	}
+
+	if err := s.Repository.DeleteDocument(ctx, "board-game-image-uploads", imageUploadId); err != nil {
+		return apierror.Internal("failed to delete image upload", err)
+	}
+	return nil
+}
+
+// playerResult is a single player's name and total score taken from a scorecard.
+type playerResult struct {
+	Name  string
+	Total float64
+}
+
+// playerResultsFromScorecard extracts the named players and their totals,
+// skipping players the parser could not name and any repeated names.
+func playerResultsFromScorecard(ctx context.Context, scorecard *documents.ScorecardDocumentCreate) []playerResult {
+	if scorecard.PlayerScores == nil {
+		return nil
+	}
+
+	var results []playerResult
+	seen := make(map[string]bool)
+	for _, player := range *scorecard.PlayerScores {
+		name, ok := player["name"].(string)
+		if !ok || name == "" || name == "unknown" {
+			continue
+		}
+		name = strings.ToLower(name)
+		if seen[name] {
+			logging.FromContext(ctx).Warn("Skipping repeated player", "player", name, "scorecard_id", scorecard.ID)
+			continue
+		}
+		total, ok := scoreAsFloat(player["total"])
+		if !ok {
+			logging.FromContext(ctx).Warn("Skipping player without a numeric total", "player", name, "scorecard_id", scorecard.ID)
+			continue
+		}
+		seen[name] = true
+		results = append(results, playerResult{Name: name, Total: total})
+	}
+	return results
+}
+
+// scoreAsFloat normalizes the numeric types a score may be decoded as, since
+// JSON yields float64 while Firestore yields int64.
+func scoreAsFloat(value any) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float64:
+		return v, true
+	default:
+		return 0, false
+	}
+}
+
+// bestTotal returns the highest total among results, which must not be empty.
+func bestTotal(results []playerResult) float64 {
+	best := results[0].Total
+	for _, result := range results[1:] {
+		best = math.Max(best, result.Total)
+	}
+	return best
+}
+
+func ratingID(scope, player string) string {
+	return fmt.Sprintf("%s_%s", scope, player)
+}
+
+// RecomputeRatingsFrom replays every completed scorecard dated on or after from
+// on top of each player's rating before it, then rewrites the history and
+// current ratings. Passing the zero time rebuilds all ratings from scratch.
+func (s *ScoreService) RecomputeRatingsFrom(ctx context.Context, from time.Time) error {
+	s.ratingsMu.Lock()
+	defer s.ratingsMu.Unlock()
+	release, err := s.acquireRatingsLease(ctx)
 	if err != nil {
-		log.Printf("Error fetching image upload metadata: %v", err)
 		return err
 	}
-	var imageUploadData documents.ImageUploadCreate
-	if err := imageUploadMetadata.DataTo(&imageUploadData); err != nil {
-		log.Printf("Error converting image upload metadata: %v", err)
+	defer release()
+
+	if !from.IsZero() {
+		from = helpers.TimeAsCalendarDateOnly(from)
+	}
+
+	state, err := s.ratingsBefore(ctx, from)
+	if err != nil {
 		return err
 	}
 
-	// delete the image from bucket
-	if imageUploadData.Bucket != "" && imageUploadData.Path != "" {
-		err := service.Repository.DeleteImage(ctx, imageUploadData.Path)
+	scorecards, err := s.Repository.ListGameScorecardsSince(ctx, from)
+	if err != nil {
+		return apierror.Internal("failed to list scorecards", err)
+	}
+	now := time.Now().In(time.UTC)
+	history := replayRatings(ctx, state, scorecards, now)
+
+	if err := s.Repository.ReplaceRatingHistorySince(ctx, from, history); err != nil {
+		return apierror.Internal("failed to write rating history", err)
+	}
+
+	ratings := make([]PlayerRatingDocument, 0, len(state))
+	for _, rating := range state {
+		rating.UpdatedAt = now
+		ratings = append(ratings, *rating)
+	}
+	if err := s.Repository.ReplacePlayerRatings(ctx, ratings); err != nil {
+		return apierror.Internal("failed to write ratings", err)
+	}
+	return nil
+}
+
+// replayRatings applies every completed scorecard, oldest first, on top of
+// state, which is updated in place, and returns the history entries written
+// along the way.
+func replayRatings(ctx context.Context, state map[string]*PlayerRatingDocument, scorecards []documents.ScorecardDocumentCreate, now time.Time) []RatingHistoryDocument {
+	sort.SliceStable(scorecards, func(i, j int) bool {
+		if !scorecards[i].Date.Equal(scorecards[j].Date) {
+			return scorecards[i].Date.Before(scorecards[j].Date)
+		}
+		if !scorecards[i].CreatedAt.Equal(scorecards[j].CreatedAt) {
+			return scorecards[i].CreatedAt.Before(scorecards[j].CreatedAt)
+		}
+		return scorecards[i].ID < scorecards[j].ID
+	})
+
+	var history []RatingHistoryDocument
+	for i := range scorecards {
+		scorecard := &scorecards[i]
+		if !scorecard.IsCompleted {
+			continue
+		}
+		results := playerResultsFromScorecard(ctx, scorecard)
+		if len(results) < 2 {
+			continue
+		}
+
+		best := bestTotal(results)
+
+		for _, scope := range []string{ratingScopeOverall, scorecard.Game} {
+			eloResults := make([]elo.Result, 0, len(results))
+			for _, result := range results {
+				id := ratingID(scope, result.Name)
+				if _, ok := state[id]; !ok {
+					state[id] = &PlayerRatingDocument{ID: id, Player: result.Name, Scope: scope, Rating: elo.DefaultRating}
+				}
+				eloResults = append(eloResults, elo.Result{Player: result.Name, Rating: state[id].Rating, Score: result.Total})
+			}
+
+			updated := elo.Update(eloResults, elo.DefaultK)
+			for _, result := range results {
+				rating := state[ratingID(scope, result.Name)]
+				before := rating.Rating
+				rating.Rating = updated[result.Name]
+				rating.GamesPlayed++
+				if result.Total == best {
+					rating.Wins++
+				}
+				rating.LastScorecardID = scorecard.ID
+				rating.LastPlayedAt = scorecard.Date
+
+				history = append(history, RatingHistoryDocument{
+					ID:           fmt.Sprintf("%s_%s", rating.ID, scorecard.ID),
+					Player:       result.Name,
+					Scope:        scope,
+					ScorecardID:  scorecard.ID,
+					Date:         scorecard.Date,
+					RatingBefore: before,
+					RatingAfter:  rating.Rating,
+					GamesPlayed:  rating.GamesPlayed,
+					Wins:         rating.Wins,
+					CreatedAt:    now,
+				})
+			}
+		}
+	}
+	return history
+}
+
+// acquireRatingsLease waits until this instance may replay ratings, returning
+// a func that gives the lease back.
+func (s *ScoreService) acquireRatingsLease(ctx context.Context) (func(), error) {
+	holder := uuid.New().String()
+	for {
+		acquired, err := s.Repository.AcquireLease(ctx, ratingsLease, holder, ratingsLeaseTTL)
 		if err != nil {
-			log.Printf("Error deleting image from bucket: %v", err)
-			return err
+			return nil, apierror.Internal("failed to acquire ratings lease", err)
+		}
+		if acquired {
+			return func() {
+				if err := s.Repository.ReleaseLease(context.WithoutCancel(ctx), ratingsLease, holder); err != nil {
+					logging.FromContext(ctx).Error("Error releasing ratings lease", "error", err)
+				}
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, apierror.Internal("gave up waiting for ratings lease", ctx.Err())
+		case <-time.After(ratingsLeaseRetry):
 		}
 	}
+}
 
-	// delete the image upload document
-	err = service.Repository.DeleteDocument(ctx, "board-game-image-uploads", scorecardData.ImageUploadMetadataID)
+// ratingsBefore returns each player's rating as it stood before from. That is
+// their current rating unless they have played since, in which case it is
+// rebuilt from their last history entry before from.
+func (s *ScoreService) ratingsBefore(ctx context.Context, from time.Time) (map[string]*PlayerRatingDocument, error) {
+	if from.IsZero() {
+		return make(map[string]*PlayerRatingDocument), nil
+	}
+
+	current, err := s.Repository.ListAllPlayerRatings(ctx)
 	if err != nil {
-		log.Printf("Error deleting image upload document: %v", err)
+		return nil, apierror.Internal("failed to list ratings", err)
+	}
+	var history []RatingHistoryDocument
+	// the earlier history is only needed when someone has played since from
+	if slices.ContainsFunc(current, func(rating PlayerRatingDocument) bool { return !rating.LastPlayedAt.Before(from) }) {
+		history, err = s.Repository.ListRatingHistoryBefore(ctx, from)
+		if err != nil {
+			return nil, apierror.Internal("failed to list rating history", err)
+		}
+	}
+	return seedRatings(current, history, from), nil
+}
+
+// seedRatings is the state a replay from from starts with: each current rating
+// last played before from as is, and every other rating as of its last entry in
+// history dated before from. Ratings with no such entry start from scratch.
+func seedRatings(current []PlayerRatingDocument, history []RatingHistoryDocument, from time.Time) map[string]*PlayerRatingDocument {
+	latest := make(map[string]*RatingHistoryDocument)
+	for i := range history {
+		entry := &history[i]
+		if !entry.Date.Before(from) {
+			continue
+		}
+		// games played is cumulative, so it orders entries that share a date
+		id := ratingID(entry.Scope, entry.Player)
+		if previous, ok := latest[id]; !ok || entry.GamesPlayed > previous.GamesPlayed {
+			latest[id] = entry
+		}
+	}
+
+	state := make(map[string]*PlayerRatingDocument)
+	for i := range current {
+		rating := &current[i]
+		if rating.LastPlayedAt.Before(from) {
+			state[rating.ID] = rating
+			continue
+		}
+		entry, ok := latest[rating.ID]
+		if !ok {
+			continue
+		}
+		state[rating.ID] = &PlayerRatingDocument{
+			ID:              rating.ID,
+			Player:          entry.Player,
+			Scope:           entry.Scope,
+			Rating:          entry.RatingAfter,
+			GamesPlayed:     entry.GamesPlayed,
+			Wins:            entry.Wins,
+			LastScorecardID: entry.ScorecardID,
+			LastPlayedAt:    entry.Date,
+		}
+	}
+	return state
+}
+
+// refreshRatingsFrom recomputes ratings after a scorecard write. The scorecard
+// itself is already persisted at this point, so failures are only logged and
+// can be repaired with a full recompute. The replay runs in the background so
+// the response does not wait on it, and is drained on shutdown.
+func (s *ScoreService) refreshRatingsFrom(ctx context.Context, from time.Time) {
+	// the job outlives the request but keeps logging under its request id
+	logger := logging.FromContext(ctx).With("ratings_from", from.Format(time.DateOnly))
+	refresh := func(ctx context.Context) {
+		ctx = logging.WithLogger(ctx, logger)
+		if err := s.RecomputeRatingsFrom(ctx, from); err != nil {
+			logger.Error("Error recomputing ratings", "error", err)
+		}
+	}
+	if s.Jobs == nil {
+		refresh(ctx)
+		return
+	}
+	if !s.Jobs.Go("ratings refresh", refresh) {
+		logger.Warn("Ratings refresh skipped during shutdown, run a recompute")
+	}
+}
+
+// HeadToHeadFilter narrows which scorecards are compared. Zero values are ignored.
+type HeadToHeadFilter struct {
+	Game string
+	From time.Time
+	To   time.Time
+}
+
+// HeadToHeadStreak is a run of consecutive wins by a single player.
+type HeadToHeadStreak struct {
+	Player string    `json:"player"`
+	Length int       `json:"length"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+}
+
+// HeadToHeadStats summarizes every completed scorecard two players both appear on.
+// Margins and category differences are always player A minus player B.
+type HeadToHeadStats struct {
+	PlayerA             string                        `json:"player_a"`
+	PlayerB             string                        `json:"player_b"`
+	GamesPlayed         int                           `json:"games_played"`
+	WinsA               int                           `json:"wins_a"`
+	WinsB               int                           `json:"wins_b"`
+	Ties                int                           `json:"ties"`
+	AverageMargin       float64                       `json:"average_margin"`
+	CategoryDifferences map[string]map[string]float64 `json:"category_differences"`
+	LongestStreak       *HeadToHeadStreak             `json:"longest_streak"`
+}
+
+// findPlayerScores returns the score row for the named player, if present.
+func findPlayerScores(scorecard *documents.ScorecardDocumentCreate, name string) map[string]any {
+	if scorecard.PlayerScores == nil {
+		return nil
+	}
+	for _, player := range *scorecard.PlayerScores {
+		if playerName, ok := player["name"].(string); ok && strings.ToLower(playerName) == name {
+			return player
+		}
+	}
+	return nil
+}
+
+// ComputeHeadToHead compares two different players across every completed
+// scorecard they both appear on.
+func (s *ScoreService) ComputeHeadToHead(ctx context.Context, playerA, playerB string, filter HeadToHeadFilter) (*HeadToHeadStats, error) {
+	playerA = strings.ToLower(playerA)
+	playerB = strings.ToLower(playerB)
+	if playerA == playerB {
+		return nil, apierror.Validation("invalid_players", "a and b must be different players")
+	}
+
+	scorecards, err := s.Repository.ListGameScorecardsSince(ctx, filter.From)
+	if err != nil {
+		return nil, apierror.Internal("failed to list scorecards", err)
+	}
+	return headToHead(scorecards, playerA, playerB, filter), nil
+}
+
+// headToHead compares lowercased playerA and playerB across scorecards, which
+// are sorted in place, skipping any filter excludes.
+func headToHead(scorecards []documents.ScorecardDocumentCreate, playerA, playerB string, filter HeadToHeadFilter) *HeadToHeadStats {
+	sort.SliceStable(scorecards, func(i, j int) bool {
+		if !scorecards[i].Date.Equal(scorecards[j].Date) {
+			return scorecards[i].Date.Before(scorecards[j].Date)
+		}
+		return scorecards[i].CreatedAt.Before(scorecards[j].CreatedAt)
+	})
+
+	stats := &HeadToHeadStats{
+		PlayerA:             playerA,
+		PlayerB:             playerB,
+		CategoryDifferences: make(map[string]map[string]float64),
+	}
+	categoryCounts := make(map[string]map[string]int)
+	totalMargin := 0.0
+	var current *HeadToHeadStreak
+
+	for i := range scorecards {
+		scorecard := &scorecards[i]
+		if !scorecard.IsCompleted {
+			continue
+		}
+		if filter.Game != "" && scorecard.Game != filter.Game {
+			continue
+		}
+		if scorecard.Date.Before(filter.From) {
+			continue
+		}
+		if !filter.To.IsZero() && scorecard.Date.After(filter.To) {
+			continue
+		}
+
+		scoresA := findPlayerScores(scorecard, playerA)
+		scoresB := findPlayerScores(scorecard, playerB)
+		if scoresA == nil || scoresB == nil {
+			continue
+		}
+		totalA, okA := scoreAsFloat(scoresA["total"])
+		totalB, okB := scoreAsFloat(scoresB["total"])
+		if !okA || !okB {
+			continue
+		}
+
+		stats.GamesPlayed++
+		totalMargin += totalA - totalB
+
+		// sum per-category differences, averaged once every scorecard is seen
+		if _, ok := stats.CategoryDifferences[scorecard.Game]; !ok {
+			stats.CategoryDifferences[scorecard.Game] = make(map[string]float64)
+			categoryCounts[scorecard.Game] = make(map[string]int)
+		}
+		for category, valueA := range scoresA {
+			if category == "name" || category == "id" || category == "total" {
+				continue
+			}
+			a, okA := scoreAsFloat(valueA)
+			b, okB := scoreAsFloat(scoresB[category])
+			if !okA || !okB {
+				continue
+			}
+			stats.CategoryDifferences[scorecard.Game][category] += a - b
+			categoryCounts[scorecard.Game][category]++
+		}
+
+		// track the result and any streak it extends
+		winner := ""
+		switch {
+		case totalA > totalB:
+			stats.WinsA++
+			winner = playerA
+		case totalB > totalA:
+			stats.WinsB++
+			winner = playerB
+		default:
+			stats.Ties++
+		}
+		if winner == "" {
+			current = nil
+			continue
+		}
+		if current == nil || current.Player != winner {
+			current = &HeadToHeadStreak{Player: winner, From: scorecard.Date}
+		}
+		current.Length++
+		current.To = scorecard.Date
+		if stats.LongestStreak == nil || current.Length > stats.LongestStreak.Length {
+			streak := *current
+			stats.LongestStreak = &streak
+		}
+	}
+
+	if stats.GamesPlayed > 0 {
+		stats.AverageMargin = totalMargin / float64(stats.GamesPlayed)
+	}
+	for game, categories := range stats.CategoryDifferences {
+		for category, total := range categories {
+			categories[category] = total / float64(categoryCounts[game][category])
+		}
+	}
+
+	return stats
+}
+
+// SessionGame is a single scorecard played during a session.
+type SessionGame struct {
+	ScorecardID string    `json:"scorecard_id"`
+	Game        string    `json:"game"`
+	Date        time.Time `json:"date"`
+	IsCompleted bool      `json:"is_completed"`
+	Winners     []string  `json:"winners"`
+}
+
+// SessionSummary lists every game played during a session and who won the
+// night, where the overall winner is whoever won the most completed games.
+type SessionSummary struct {
+	Session        *SessionDocument `json:"session"`
+	Games          []SessionGame    `json:"games"`
+	Wins           map[string]int   `json:"wins"`
+	OverallWinners []string         `json:"overall_winners"`
+}
+
+// CreateSession saves a new game night for actor. input is expected to have
+// been validated, as request binding does.
+func (s *ScoreService) CreateSession(ctx context.Context, actor Actor, input SessionRequest) (*SessionDocument, error) {
+	location := strings.TrimSpace(input.Location)
+	if location == "" {
+		location = "unknown"
+	}
+	session := SessionDocument{
+		ID:        uuid.New().String(),
+		Date:      helpers.TimeAsCalendarDateOnly(input.Date),
+		Location:  location,
+		Attendees: normalizeAttendees(input.Attendees),
+		Notes:     input.Notes,
+		CreatedBy: &actor.Email,
+		CreatedAt: time.Now().In(time.UTC),
+	}
+	if err := s.Repository.SaveSession(ctx, &session); err != nil {
+		return nil, apierror.Internal("failed to save session", err)
+	}
+	return &session, nil
+}
+
+// GetSession returns a session, or a not found error.
+func (s *ScoreService) GetSession(ctx context.Context, sessionId string) (*SessionDocument, error) {
+	if err := s.RequireSession(ctx, sessionId); err != nil {
+		return nil, err
+	}
+	session, err := s.Repository.GetSession(ctx, sessionId)
+	if err != nil {
+		return nil, apierror.Internal("failed to fetch session", err)
+	}
+	return session, nil
+}
+
+// UpdateSession applies the non-nil fields of update to a session.
+func (s *ScoreService) UpdateSession(ctx context.Context, actor Actor, sessionId string, update SessionUpdate) error {
+	if err := s.RequireSession(ctx, sessionId); err != nil {
+		return err
+	}
+
+	var updates []firestore.Update
+	if update.Date != nil {
+		updates = append(updates, firestore.Update{Path: "date", Value: helpers.TimeAsCalendarDateOnly(*update.Date)})
+	}
+	if update.Location != nil {
+		updates = append(updates, firestore.Update{Path: "location", Value: strings.TrimSpace(*update.Location)})
+	}
+	if update.Attendees != nil {
+		updates = append(updates, firestore.Update{Path: "attendees", Value: normalizeAttendees(*update.Attendees)})
+	}
+	if update.Notes != nil {
+		updates = append(updates, firestore.Update{Path: "notes", Value: *update.Notes})
+	}
+	if len(updates) == 0 {
+		return apierror.Validation("no_updates", "no updates provided")
+	}
+
+	// add updated by and updated at
+	updates = append(updates, firestore.Update{Path: "updated_by", Value: actor.Email})
+	updates = append(updates, firestore.Update{Path: "updated_at", Value: time.Now().In(time.UTC)})
+
+	if err := s.Repository.UpdateSession(ctx, sessionId, updates); err != nil {
+		return apierror.Internal("failed to update session", err)
+	}
+	return nil
+}
+
+// BuildSessionSummary lists the games played during a session and who won them.
+func (s *ScoreService) BuildSessionSummary(ctx context.Context, sessionId string) (*SessionSummary, error) {
+	session, err := s.GetSession(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+
+	scorecards, err := s.Repository.ListGameScorecardsBySession(ctx, sessionId)
+	if err != nil {
+		return nil, apierror.Internal("failed to list session scorecards", err)
+	}
+
+	summary := &SessionSummary{
+		Session:        session,
+		Games:          make([]SessionGame, 0, len(scorecards)),
+		Wins:           make(map[string]int),
+		OverallWinners: []string{},
+	}
+	for i := range scorecards {
+		scorecard := &scorecards[i]
+		game := SessionGame{
+			ScorecardID: scorecard.ID,
+			Game:        scorecard.Game,
+			Date:        scorecard.Date,
+			IsCompleted: scorecard.IsCompleted,
+			Winners:     []string{},
+		}
+
+		// only completed scorecards count towards the night's winner
+		results := playerResultsFromScorecard(ctx, scorecard)
+		if scorecard.IsCompleted && len(results) > 0 {
+			best := bestTotal(results)
+			for _, result := range results {
+				if result.Total == best {
+					game.Winners = append(game.Winners, result.Name)
+					summary.Wins[result.Name]++
+				}
+			}
+		}
+		summary.Games = append(summary.Games, game)
+	}
+
+	mostWins := 0
+	for player, wins := range summary.Wins {
+		switch {
+		case wins > mostWins:
+			mostWins = wins
+			summary.OverallWinners = []string{player}
+		case wins == mostWins:
+			summary.OverallWinners = append(summary.OverallWinners, player)
+		}
+	}
+	sort.Strings(summary.OverallWinners)
+
+	return summary, nil
+}
+
+// DeleteSession unlinks every scorecard played during the session before
+// deleting it. The scorecards themselves are kept.
+func (s *ScoreService) DeleteSession(ctx context.Context, sessionId string) error {
+	if err := s.RequireSession(ctx, sessionId); err != nil {
 		return err
 	}
 
-	// delete the scorecard
-	err = service.Repository.DeleteDocument(ctx, "board-game-scorecards", scorecardId)
+	scorecards, err := s.Repository.ListGameScorecardsBySession(ctx, sessionId)
+	if err != nil {
+		return apierror.Internal("failed to list session scorecards", err)
+	}
+	for _, scorecard := range scorecards {
+		if err := s.Repository.SetScorecardSession(ctx, scorecard.ID, ""); err != nil {
+			return apierror.Internal(fmt.Sprintf("failed to unlink scorecard %s", scorecard.ID), err)
+		}
+	}
+
+	if err := s.Repository.DeleteDocument(ctx, "board-game-sessions", sessionId); err != nil {
+		return apierror.Internal("failed to delete session", err)
+	}
+	return nil
+}
+
+// LinkScorecard records that a scorecard was played during a session,
+// replacing any session it was linked to before.
+func (s *ScoreService) LinkScorecard(ctx context.Context, sessionId, documentId string) error {
+	if err := s.RequireSession(ctx, sessionId); err != nil {
+		return err
+	}
+	if _, err := s.GetScorecard(ctx, documentId); err != nil {
+		return err
+	}
+	if err := s.Repository.SetScorecardSession(ctx, documentId, sessionId); err != nil {
+		return apierror.Internal("failed to link scorecard to session", err)
+	}
+	return nil
+}
+
+// UnlinkScorecard clears a scorecard's link to a session, keeping the
+// scorecard. Scorecards not linked to the session are not found.
+func (s *ScoreService) UnlinkScorecard(ctx context.Context, sessionId, documentId string) error {
+	if err := s.RequireSession(ctx, sessionId); err != nil {
+		return err
+	}
+	if _, err := s.GetScorecard(ctx, documentId); err != nil {
+		return err
+	}
+	linkedTo, err := s.Repository.GetScorecardSessionID(ctx, documentId)
+	if err != nil {
+		return apierror.Internal("failed to fetch scorecard session", err)
+	}
+	if linkedTo != sessionId {
+		return apierror.NotFound("scorecard_not_in_session", fmt.Sprintf("Document with ID %s is not linked to session %s", documentId, sessionId))
+	}
+	if err := s.Repository.SetScorecardSession(ctx, documentId, ""); err != nil {
+		return apierror.Internal("failed to unlink scorecard from session", err)
+	}
+	return nil
+}
+
+// sessionIdFromImageUpload returns the session an image was uploaded for, or an
+// empty string if there was none or that session has since been deleted.
+func (s *ScoreService) sessionIdFromImageUpload(ctx context.Context, imageUploadId string) string {
+	sessionId, err := s.Repository.GetImageUploadSessionID(ctx, imageUploadId)
+	if err != nil {
+		logging.FromContext(ctx).Error("Error looking up image upload session", "error", err)
+		return ""
+	}
+	if sessionId == "" {
+		return ""
+	}
+	exists, err := s.Repository.CheckDocumentExists(ctx, "board-game-sessions", sessionId)
+	if err != nil || !exists {
+		return ""
+	}
+	return sessionId
+}
+
+// normalizeAttendees lowercases and de-duplicates attendee names so they match
+// the player names the parser stores on scorecards.
+func normalizeAttendees(attendees []string) []string {
+	normalized := make([]string, 0, len(attendees))
+	seen := make(map[string]bool)
+	for _, attendee := range attendees {
+		attendee = strings.ToLower(strings.TrimSpace(attendee))
+		if attendee == "" || seen[attendee] {
+			continue
+		}
+		seen[attendee] = true
+		normalized = append(normalized, attendee)
+	}
+	return normalized
+}
+
+// locationMatchThreshold is the minimum similarity for a free text location to
+// be treated as a misspelling of a known name or alias.
+const locationMatchThreshold = 0.8
+
+// locationKeys returns the normalized name and aliases a location is known by.
+func locationKeys(location *LocationDocument) []string {
+	keys := []string{helpers.NormalizeName(location.Name)}
+	for _, alias := range location.Aliases {
+		keys = append(keys, helpers.NormalizeName(alias))
+	}
+	return keys
+}
+
+// MatchLocation finds the location a free text string most likely refers to.
+// Exact matches on the normalized name or an alias win outright, otherwise the
+// closest name or alias is used if it is similar enough.
+func MatchLocation(locations []LocationDocument, raw string) *LocationDocument {
+	normalized := helpers.NormalizeName(raw)
+	if normalized == "" {
+		return nil
+	}
+
+	var best *LocationDocument
+	bestScore := 0.0
+	for i := range locations {
+		for _, key := range locationKeys(&locations[i]) {
+			if key == normalized {
+				return &locations[i]
+			}
+			if score := helpers.StringSimilarity(key, normalized); score > bestScore {
+				best = &locations[i]
+				bestScore = score
+			}
+		}
+	}
+	if bestScore >= locationMatchThreshold {
+		return best
+	}
+	return nil
+}
+
+// ResolveLocation maps a free text location onto the canonical name from the
+// location registry, returning the text unchanged when nothing matches.
+func (s *ScoreService) ResolveLocation(ctx context.Context, raw string) string {
+	locations, err := s.Repository.ListLocations(ctx)
 	if err != nil {
-		log.Printf("Error deleting scorecard document: %v", err)
+		logging.FromContext(ctx).Warn("Unable to list locations, keeping raw location", "location", raw, "error", err)
+		return raw
+	}
+	if match := MatchLocation(locations, raw); match != nil {
+		return match.Name
+	}
+	return raw
+}
+
+// normalizeAliases normalizes and de-duplicates aliases, dropping any that
+// are the same as the location name.
+func normalizeAliases(name string, aliases []string) []string {
+	normalized := make([]string, 0, len(aliases))
+	seen := map[string]bool{helpers.NormalizeName(name): true}
+	for _, alias := range aliases {
+		alias = helpers.NormalizeName(alias)
+		if alias == "" || seen[alias] {
+			continue
+		}
+		seen[alias] = true
+		normalized = append(normalized, alias)
+	}
+	return normalized
+}
+
+// scorecardsAtLocation returns the scorecards whose location text exactly
+// matches one of the location's normalized keys.
+func scorecardsAtLocation(scorecards []documents.ScorecardDocumentCreate, location *LocationDocument) []documents.ScorecardDocumentCreate {
+	keys := make(map[string]bool)
+	for _, key := range locationKeys(location) {
+		keys[key] = true
+	}
+
+	var matched []documents.ScorecardDocumentCreate
+	for _, scorecard := range scorecards {
+		if scorecard.Location != nil && keys[helpers.NormalizeName(*scorecard.Location)] {
+			matched = append(matched, scorecard)
+		}
+	}
+	return matched
+}
+
+// requireLocation returns a not found error when the location id does not
+// refer to an existing location.
+func (s *ScoreService) requireLocation(ctx context.Context, locationId string) error {
+	exists, err := s.Repository.CheckDocumentExists(ctx, "board-game-locations", locationId)
+	if err != nil {
+		return apierror.Internal("failed to check location existence", err)
+	}
+	if !exists {
+		return apierror.NotFound("location_not_found", fmt.Sprintf("Location with ID %s not found", locationId))
+	}
+	return nil
+}
+
+// requireLocationNameAvailable returns a conflict error when name or one of
+// aliases already belongs to a location other than those in ignoreIds.
+func (s *ScoreService) requireLocationNameAvailable(ctx context.Context, name string, aliases []string, ignoreIds ...string) error {
+	locations, err := s.Repository.ListLocations(ctx)
+	if err != nil {
+		return apierror.Internal("failed to list locations", err)
+	}
+	return checkLocationNames(locations, name, aliases, ignoreIds...)
+}
+
+// checkLocationNames returns a conflict error when name or one of aliases is
+// already the name or an alias of a location other than those in ignoreIds,
+// which would make MatchLocation ambiguous.
+func checkLocationNames(locations []LocationDocument, name string, aliases []string, ignoreIds ...string) error {
+	taken := make(map[string]string)
+	for i := range locations {
+		if slices.Contains(ignoreIds, locations[i].ID) {
+			continue
+		}
+		for _, key := range locationKeys(&locations[i]) {
+			taken[key] = locations[i].Name
+		}
+	}
+	for _, candidate := range append([]string{name}, aliases...) {
+		if owner, ok := taken[helpers.NormalizeName(candidate)]; ok {
+			return apierror.Conflict("location_name_taken", fmt.Sprintf("%s is already used by location %s", candidate, owner))
+		}
+	}
+	return nil
+}
+
+// CreateLocation adds a location to the registry for actor. Its name and
+// aliases must not belong to another location.
+func (s *ScoreService) CreateLocation(ctx context.Context, actor Actor, input LocationRequest) (*LocationDocument, error) {
+	name := strings.TrimSpace(input.Name)
+	if helpers.NormalizeName(name) == "" {
+		return nil, apierror.Validation("name_required", "name is required")
+	}
+	if err := s.requireLocationNameAvailable(ctx, name, input.Aliases); err != nil {
+		return nil, err
+	}
+
+	location := LocationDocument{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Aliases:   normalizeAliases(name, input.Aliases),
+		CreatedBy: &actor.Email,
+		CreatedAt: time.Now().In(time.UTC),
+	}
+	if err := s.Repository.SaveLocation(ctx, &location); err != nil {
+		return nil, apierror.Internal("failed to save location", err)
+	}
+	return &location, nil
+}
+
+// GetLocation returns a location, or a not found error.
+func (s *ScoreService) GetLocation(ctx context.Context, locationId string) (*LocationDocument, error) {
+	if err := s.requireLocation(ctx, locationId); err != nil {
+		return nil, err
+	}
+	location, err := s.Repository.GetLocation(ctx, locationId)
+	if err != nil {
+		return nil, apierror.Internal("failed to fetch location", err)
+	}
+	return location, nil
+}
+
+// UpdateLocation applies the non-nil fields of update to a location, as long
+// as the resulting name and aliases do not belong to another location.
+func (s *ScoreService) UpdateLocation(ctx context.Context, actor Actor, locationId string, update LocationUpdate) error {
+	location, err := s.GetLocation(ctx, locationId)
+	if err != nil {
+		return err
+	}
+	if update.Name == nil && update.Aliases == nil {
+		return apierror.Validation("no_updates", "no updates provided")
+	}
+
+	// apply the update to a copy first so the combined names can be checked for conflicts
+	name := location.Name
+	if update.Name != nil {
+		name = strings.TrimSpace(*update.Name)
+		if helpers.NormalizeName(name) == "" {
+			return apierror.Validation("name_required", "name cannot be empty")
+		}
+	}
+	aliases := location.Aliases
+	if update.Aliases != nil {
+		aliases = *update.Aliases
+	}
+	if err := s.requireLocationNameAvailable(ctx, name, aliases, locationId); err != nil {
 		return err
 	}
 
+	updates := []firestore.Update{
+		{Path: "name", Value: name},
+		{Path: "aliases", Value: normalizeAliases(name, aliases)},
+		{Path: "updated_by", Value: actor.Email},
+		{Path: "updated_at", Value: time.Now().In(time.UTC)},
+	}
+	if err := s.Repository.UpdateLocation(ctx, locationId, updates); err != nil {
+		return apierror.Internal("failed to update location", err)
+	}
+	return nil
+}
+
+// DeleteLocation removes a location from the registry. Scorecards keep their
+// location text.
+func (s *ScoreService) DeleteLocation(ctx context.Context, locationId string) error {
+	if err := s.requireLocation(ctx, locationId); err != nil {
+		return err
+	}
+	if err := s.Repository.DeleteDocument(ctx, "board-game-locations", locationId); err != nil {
+		return apierror.Internal("failed to delete location", err)
+	}
 	return nil
 }
+
+// MergeLocations folds source into target: the source name and aliases become
+// aliases of target, source is deleted and scorecards recorded at source are
+// moved to target. The merged names must not belong to any other location.
+func (s *ScoreService) MergeLocations(ctx context.Context, actor Actor, targetId, sourceId string) (*LocationDocument, error) {
+	if targetId == sourceId {
+		return nil, apierror.Validation("invalid_merge", "cannot merge a location into itself")
+	}
+	target, err := s.GetLocation(ctx, targetId)
+	if err != nil {
+		return nil, err
+	}
+	source, err := s.GetLocation(ctx, sourceId)
+	if err != nil {
+		return nil, err
+	}
+
+	aliases := mergedAliases(target, source)
+	if err := s.requireLocationNameAvailable(ctx, target.Name, aliases, targetId, sourceId); err != nil {
+		return nil, err
+	}
+
+	// find the scorecards to move before source, and the aliases it matched them by, are gone
+	scorecards, err := s.Repository.ListGameScorecardsSince(ctx, time.Time{})
+	if err != nil {
+		return nil, apierror.Internal("failed to list scorecards", err)
+	}
+	var scorecardIds []string
+	for _, scorecard := range scorecardsAtLocation(scorecards, source) {
+		scorecardIds = append(scorecardIds, scorecard.ID)
+	}
+
+	target.Aliases = aliases
+	now := time.Now().In(time.UTC)
+	target.UpdatedBy = &actor.Email
+	target.UpdatedAt = &now
+	if err := s.Repository.MergeLocation(ctx, target, sourceId); err != nil {
+		return nil, apierror.Internal("failed to merge locations", err)
+	}
+
+	// scorecards are moved last, since any left behind by a failure still
+	// match target through the aliases it took over from source
+	if err := s.Repository.SetGameScorecardLocations(ctx, scorecardIds, target.Name); err != nil {
+		return nil, apierror.Internal("failed to move scorecards", err)
+	}
+	return target, nil
+}
+
+// mergedAliases is every alias target has once source is merged into it.
+func mergedAliases(target, source *LocationDocument) []string {
+	aliases := append(slices.Clone(target.Aliases), source.Name)
+	return normalizeAliases(target.Name, append(aliases, source.Aliases...))
+}
+
+// LocationStats summarizes the games played at a location.
+type LocationStats struct {
+	Location        *LocationDocument `json:"location"`
+	GamesPlayed     int               `json:"games_played"`
+	GamesByGame     map[string]int    `json:"games_by_game"`
+	MostPlayedGame  *string           `json:"most_played_game"`
+	WinsByPlayer    map[string]int    `json:"wins_by_player"`
+	TopWinners      []string          `json:"top_winners"`
+	TopWinnerWins   int               `json:"top_winner_wins"`
+	FirstPlayedDate *time.Time        `json:"first_played_date"`
+	LastPlayedDate  *time.Time        `json:"last_played_date"`
+}
+
+// ComputeLocationStats summarizes every scorecard recorded at a location.
+func (s *ScoreService) ComputeLocationStats(ctx context.Context, locationId string) (*LocationStats, error) {
+	location, err := s.GetLocation(ctx, locationId)
+	if err != nil {
+		return nil, err
+	}
+
+	scorecards, err := s.Repository.ListGameScorecardsSince(ctx, time.Time{})
+	if err != nil {
+		return nil, apierror.Internal("failed to list scorecards", err)
+	}
+
+	stats := &LocationStats{
+		Location:     location,
+		GamesByGame:  make(map[string]int),
+		WinsByPlayer: make(map[string]int),
+		TopWinners:   []string{},
+	}
+	for _, scorecard := range scorecardsAtLocation(scorecards, location) {
+		stats.GamesPlayed++
+		stats.GamesByGame[scorecard.Game]++
+		if stats.FirstPlayedDate == nil || scorecard.Date.Before(*stats.FirstPlayedDate) {
+			date := scorecard.Date
+			stats.FirstPlayedDate = &date
+		}
+		if stats.LastPlayedDate == nil || scorecard.Date.After(*stats.LastPlayedDate) {
+			date := scorecard.Date
+			stats.LastPlayedDate = &date
+		}
+
+		// only completed scorecards count towards wins
+		results := playerResultsFromScorecard(ctx, &scorecard)
+		if !scorecard.IsCompleted || len(results) == 0 {
+			continue
+		}
+		best := bestTotal(results)
+		for _, result := range results {
+			if result.Total == best {
+				stats.WinsByPlayer[result.Name]++
+			}
+		}
+	}
+
+	mostPlayed := 0
+	for game, count := range stats.GamesByGame {
+		if count > mostPlayed || (count == mostPlayed && game < *stats.MostPlayedGame) {
+			mostPlayed = count
+			stats.MostPlayedGame = &game
+		}
+	}
+	for player, wins := range stats.WinsByPlayer {
+		switch {
+		case wins > stats.TopWinnerWins:
+			stats.TopWinnerWins = wins
+			stats.TopWinners = []string{player}
+		case wins == stats.TopWinnerWins:
+			stats.TopWinners = append(stats.TopWinners, player)
+		}
+	}
+	sort.Strings(stats.TopWinners)
+
+	return stats, nil
+}
@@ -0,0 +1,435 @@
+package boardgametracker
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/elo"
+	"github.com/owen-crook/board-game-tracker-go-common/pkg/documents"
+	"github.com/owen-crook/board-game-tracker-go-common/pkg/gamedata"
+	"github.com/owen-crook/board-game-tracker-go-common/pkg/games"
+)
+
+const testGame = "wingspan"
+
+// errorStatus is the HTTP status err would be reported with, or 0 for nil.
+func errorStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+	return apierror.From(err).Status()
+}
+
+func TestCheckScorecardUpload(t *testing.T) {
+	owner := "owner@example.com"
+	upload := &documents.ImageUploadCreate{ID: "upload-1", CreatedBy: &owner}
+
+	tests := []struct {
+		name   string
+		actor  Actor
+		upload *documents.ImageUploadCreate
+		want   int
+	}{
+		{"owner", Actor{Email: "Owner@Example.com", Role: auth.RoleContributor}, upload, 0},
+		{"admin", Actor{Email: "admin@example.com", Role: auth.RoleAdmin}, upload, 0},
+		{"someone else's upload", Actor{Email: "other@example.com", Role: auth.RoleContributor}, upload, http.StatusForbidden},
+		{"unknown upload", Actor{Email: "owner@example.com", Role: auth.RoleContributor}, nil, http.StatusUnprocessableEntity},
+		{"unknown upload as admin", Actor{Email: "admin@example.com", Role: auth.RoleAdmin}, nil, http.StatusUnprocessableEntity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := errorStatus(checkScorecardUpload(tt.actor, "upload-1", tt.upload)); got != tt.want {
+				t.Errorf("status = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// playerScores scores every category of game for each player, so tests do not
+// depend on which categories a game has.
+func playerScores(t *testing.T, game string, totals map[string]int) []map[string]any {
+	t.Helper()
+	categories, err := gamedata.GetScoringCategoriesByGame(games.Game(game))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scores []map[string]any
+	for name, total := range totals {
+		player := map[string]any{"name": name, "total": float64(total)}
+		for _, category := range categories {
+			player[category.ShortName] = float64(0)
+		}
+		scores = append(scores, player)
+	}
+	return scores
+}
+
+func TestCheckScorecardRequest(t *testing.T) {
+	valid := func() ScorecardRequest {
+		return ScorecardRequest{
+			Game:         testGame,
+			Date:         time.Now().UTC(),
+			PlayerScores: playerScores(t, testGame, map[string]int{"alice": 10, "bob": 8}),
+		}
+	}
+	longLocation := strings.Repeat("x", 201)
+
+	tests := []struct {
+		name   string
+		modify func(*ScorecardRequest)
+		field  string
+	}{
+		{"valid", func(*ScorecardRequest) {}, ""},
+		{"unsupported game", func(r *ScorecardRequest) { r.Game = "monopoly" }, "game"},
+		{"date before the earliest game", func(r *ScorecardRequest) { r.Date = time.Date(1999, 12, 31, 0, 0, 0, 0, time.UTC) }, "date"},
+		{"date in the future", func(r *ScorecardRequest) { r.Date = time.Now().AddDate(0, 0, 3) }, "date"},
+		{"location too long", func(r *ScorecardRequest) { r.Location = &longLocation }, "location"},
+		{"no players", func(r *ScorecardRequest) { r.PlayerScores = nil }, "player_scores"},
+		{"missing category", func(r *ScorecardRequest) {
+			for key := range r.PlayerScores[0] {
+				if key != "name" && key != "total" {
+					delete(r.PlayerScores[0], key)
+					return
+				}
+			}
+		}, "player_scores"},
+		{"unknown category", func(r *ScorecardRequest) { r.PlayerScores[0]["made_up"] = float64(1) }, "player_scores"},
+		{"blank name", func(r *ScorecardRequest) { r.PlayerScores[0]["name"] = " " }, "player_scores"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := valid()
+			tt.modify(&input)
+			err := checkScorecardRequest(input)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("err = %v, want nil", err)
+				}
+				return
+			}
+			problem := apierror.From(err)
+			if problem.Status() != http.StatusBadRequest || problem.Fields[tt.field] == "" {
+				t.Errorf("err = %v with fields %v, want a 400 on %s", err, problem.Fields, tt.field)
+			}
+		})
+	}
+}
+
+func TestMatchLocation(t *testing.T) {
+	locations := []LocationDocument{
+		{ID: "home", Name: "Home", Aliases: []string{"owens house"}},
+		{ID: "cafe", Name: "Board Game Cafe"},
+	}
+
+	tests := []struct {
+		name string
+		raw  string
+		want string
+	}{
+		{"exact name", "home", "home"},
+		{"name with punctuation and case", "  HOME! ", "home"},
+		{"alias", "Owen's House", "home"},
+		{"misspelling above the threshold", "Board Game Cafee", "cafe"},
+		{"misspelling below the threshold", "Board Gaem Caef", ""},
+		{"unknown", "unknown", ""},
+		{"empty", "  ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ""
+			if location := MatchLocation(locations, tt.raw); location != nil {
+				got = location.ID
+			}
+			if got != tt.want {
+				t.Errorf("MatchLocation(%q) = %q, want %q", tt.raw, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMergedAliases(t *testing.T) {
+	target := &LocationDocument{ID: "home", Name: "Home", Aliases: []string{"owens house"}}
+	source := &LocationDocument{ID: "flat", Name: "Owen's Flat", Aliases: []string{"the flat", "home", "Owens House"}}
+
+	got := mergedAliases(target, source)
+	if want := "owens house,owens flat,the flat"; strings.Join(got, ",") != want {
+		t.Errorf("mergedAliases = %v, want %s", got, want)
+	}
+	if len(target.Aliases) != 1 {
+		t.Errorf("target.Aliases = %v, want it left untouched", target.Aliases)
+	}
+}
+
+func TestCheckLocationNamesForMerge(t *testing.T) {
+	locations := []LocationDocument{
+		{ID: "home", Name: "Home", Aliases: []string{"owens house"}},
+		{ID: "flat", Name: "Owen's Flat", Aliases: []string{"the flat"}},
+		{ID: "cafe", Name: "Board Game Cafe", Aliases: []string{"cafe"}},
+	}
+	target, source := &locations[0], &locations[1]
+
+	tests := []struct {
+		name   string
+		source LocationDocument
+		want   int
+	}{
+		{"names only used by target and source", *source, 0},
+		{"source name used by another location", LocationDocument{ID: "flat", Name: "Board Game Cafe"}, http.StatusConflict},
+		{"source alias used by another location", LocationDocument{ID: "flat", Name: "Owen's Flat", Aliases: []string{"Cafe"}}, http.StatusConflict},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := checkLocationNames(locations, target.Name, mergedAliases(target, &tt.source), target.ID, source.ID)
+			if got := errorStatus(err); got != tt.want {
+				t.Errorf("status = %d, want %d (%v)", got, tt.want, err)
+			}
+		})
+	}
+}
+
+// completedScorecard is a completed scorecard of game played day days into 2024.
+func completedScorecard(id, game string, day int, totals map[string]float64) documents.ScorecardDocumentCreate {
+	var scores []map[string]any
+	for name, total := range totals {
+		scores = append(scores, map[string]any{"name": name, "total": total})
+	}
+	return scoredScorecard(id, game, day, scores...)
+}
+
+// scoredScorecard is a completed scorecard of game played day days into 2024
+// with the given score rows.
+func scoredScorecard(id, game string, day int, scores ...map[string]any) documents.ScorecardDocumentCreate {
+	date := time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)
+	return documents.ScorecardDocumentCreate{ID: id, Game: game, Date: date, IsCompleted: true, PlayerScores: &scores, CreatedAt: date}
+}
+
+// fullReplay rebuilds every rating from scratch, as a zero-time recompute does.
+func fullReplay(scorecards []documents.ScorecardDocumentCreate) (map[string]*PlayerRatingDocument, []RatingHistoryDocument) {
+	state := make(map[string]*PlayerRatingDocument)
+	history := replayRatings(context.Background(), state, slices.Clone(scorecards), time.Now())
+	return state, history
+}
+
+func TestReplayRatingsScopes(t *testing.T) {
+	state, history := fullReplay([]documents.ScorecardDocumentCreate{
+		completedScorecard("s1", "wingspan", 1, map[string]float64{"alice": 80, "bob": 70}),
+		completedScorecard("s2", "azul", 2, map[string]float64{"alice": 40, "bob": 60}),
+		completedScorecard("s3", "azul", 3, map[string]float64{"alice": 50}),
+		{ID: "s4", Game: "azul", Date: time.Date(2024, 1, 4, 0, 0, 0, 0, time.UTC)},
+	})
+
+	tests := []struct {
+		id     string
+		games  int
+		wins   int
+		rating float64
+	}{
+		{ratingID(ratingScopeOverall, "alice"), 2, 1, 1516 - 32*elo.ExpectedScore(1516, 1484)},
+		{ratingID("wingspan", "alice"), 1, 1, 1516},
+		{ratingID("azul", "alice"), 1, 0, 1484},
+		{ratingID("azul", "bob"), 1, 1, 1516},
+	}
+	for _, tt := range tests {
+		t.Run(tt.id, func(t *testing.T) {
+			rating, ok := state[tt.id]
+			if !ok {
+				t.Fatalf("no rating %s in %v", tt.id, state)
+			}
+			if rating.GamesPlayed != tt.games || rating.Wins != tt.wins {
+				t.Errorf("games played %d and wins %d, want %d and %d", rating.GamesPlayed, rating.Wins, tt.games, tt.wins)
+			}
+			if math.Abs(rating.Rating-tt.rating) > 1e-9 {
+				t.Errorf("rating = %v, want %v", rating.Rating, tt.rating)
+			}
+		})
+	}
+	// the solo game and the incomplete one are not rated
+	if len(state) != 6 || len(history) != 8 {
+		t.Errorf("got %d ratings and %d history entries, want 6 and 8", len(state), len(history))
+	}
+}
+
+func TestReplayRatingsIncrementally(t *testing.T) {
+	scorecards := []documents.ScorecardDocumentCreate{
+		completedScorecard("s1", "wingspan", 1, map[string]float64{"alice": 80, "bob": 70}),
+		completedScorecard("s2", "azul", 2, map[string]float64{"alice": 40, "bob": 60, "carol": 55}),
+		completedScorecard("s3", "wingspan", 2, map[string]float64{"bob": 90, "dave": 60}),
+		completedScorecard("s4", "wingspan", 3, map[string]float64{"alice": 75, "dave": 75}),
+		completedScorecard("s5", "azul", 4, map[string]float64{"alice": 30, "bob": 20, "dave": 40}),
+	}
+	withoutS2 := slices.DeleteFunc(slices.Clone(scorecards), func(scorecard documents.ScorecardDocumentCreate) bool { return scorecard.ID == "s2" })
+	edited := slices.Clone(scorecards)
+	edited[3] = completedScorecard("s4", "wingspan", 3, map[string]float64{"alice": 95, "dave": 75})
+
+	tests := []struct {
+		name  string
+		after []documents.ScorecardDocumentCreate
+		from  int
+	}{
+		{"edit", edited, 3},
+		{"delete with a player who played nothing else", withoutS2, 2},
+		{"replay of the latest day only", scorecards, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			before, history := fullReplay(scorecards)
+			var current []PlayerRatingDocument
+			for _, rating := range before {
+				current = append(current, *rating)
+			}
+			from := time.Date(2024, 1, tt.from, 0, 0, 0, 0, time.UTC)
+
+			// replay only the scorecards on or after from on top of the seeded ratings
+			state := seedRatings(current, history, from)
+			var window []documents.ScorecardDocumentCreate
+			for _, scorecard := range tt.after {
+				if !scorecard.Date.Before(from) {
+					window = append(window, scorecard)
+				}
+			}
+			replayRatings(context.Background(), state, window, time.Now())
+
+			want, _ := fullReplay(tt.after)
+			if len(state) != len(want) {
+				t.Fatalf("got ratings %v, want %v", keys(state), keys(want))
+			}
+			for id, wantRating := range want {
+				got, ok := state[id]
+				if !ok {
+					t.Fatalf("missing rating %s", id)
+				}
+				if math.Abs(got.Rating-wantRating.Rating) > 1e-9 || got.GamesPlayed != wantRating.GamesPlayed || got.Wins != wantRating.Wins ||
+					got.LastScorecardID != wantRating.LastScorecardID || !got.LastPlayedAt.Equal(wantRating.LastPlayedAt) {
+					t.Errorf("%s = %+v, want %+v", id, *got, *wantRating)
+				}
+			}
+		})
+	}
+}
+
+func keys(state map[string]*PlayerRatingDocument) []string {
+	ids := make([]string, 0, len(state))
+	for id := range state {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func TestHeadToHead(t *testing.T) {
+	row := func(name string, total float64, categories map[string]float64) map[string]any {
+		scores := map[string]any{"name": name, "total": total}
+		for category, value := range categories {
+			scores[category] = value
+		}
+		return scores
+	}
+	scorecards := []documents.ScorecardDocumentCreate{
+		scoredScorecard("s1", "wingspan", 1,
+			row("Alice", 90, map[string]float64{"birds": 40, "eggs": 10}),
+			row("bob", 80, map[string]float64{"birds": 30, "eggs": 14})),
+		scoredScorecard("s2", "wingspan", 2,
+			row("alice", 70, map[string]float64{"birds": 30, "eggs": 6}),
+			row("bob", 75, map[string]float64{"birds": 20})),
+		scoredScorecard("s3", "azul", 3,
+			row("alice", 50, map[string]float64{"rows": 12}),
+			row("bob", 50, map[string]float64{"rows": 8}),
+			row("carol", 60, nil)),
+		scoredScorecard("s4", "azul", 4,
+			row("alice", 44, nil),
+			row("carol", 40, nil)),
+		{ID: "s5", Game: "azul", Date: time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), PlayerScores: &[]map[string]any{
+			row("alice", 0, nil), row("bob", 99, nil),
+		}},
+	}
+
+	tests := []struct {
+		name        string
+		filter      HeadToHeadFilter
+		games       int
+		winsA       int
+		winsB       int
+		ties        int
+		margin      float64
+		differences map[string]map[string]float64
+	}{
+		{
+			name:  "every game",
+			games: 3, winsA: 1, winsB: 1, ties: 1,
+			margin: (10 - 5 + 0) / 3.0,
+			differences: map[string]map[string]float64{
+				// bob has no eggs on s2, so only s1 counts towards them
+				"wingspan": {"birds": 10, "eggs": -4},
+				"azul":     {"rows": 4},
+			},
+		},
+		{
+			name:   "one game",
+			filter: HeadToHeadFilter{Game: "wingspan"},
+			games:  2, winsA: 1, winsB: 1,
+			margin:      2.5,
+			differences: map[string]map[string]float64{"wingspan": {"birds": 10, "eggs": -4}},
+		},
+		{
+			name:   "date range",
+			filter: HeadToHeadFilter{From: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), To: time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)},
+			games:  1, winsB: 1,
+			margin:      -5,
+			differences: map[string]map[string]float64{"wingspan": {"birds": 10}},
+		},
+		{
+			name:        "nothing in range",
+			filter:      HeadToHeadFilter{Game: "catan"},
+			differences: map[string]map[string]float64{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := headToHead(slices.Clone(scorecards), "alice", "bob", tt.filter)
+			if stats.GamesPlayed != tt.games || stats.WinsA != tt.winsA || stats.WinsB != tt.winsB || stats.Ties != tt.ties {
+				t.Errorf("games %d, wins %d-%d, ties %d, want games %d, wins %d-%d, ties %d",
+					stats.GamesPlayed, stats.WinsA, stats.WinsB, stats.Ties, tt.games, tt.winsA, tt.winsB, tt.ties)
+			}
+			if math.Abs(stats.AverageMargin-tt.margin) > 1e-9 {
+				t.Errorf("AverageMargin = %v, want %v", stats.AverageMargin, tt.margin)
+			}
+			if len(stats.CategoryDifferences) != len(tt.differences) {
+				t.Fatalf("CategoryDifferences = %v, want %v", stats.CategoryDifferences, tt.differences)
+			}
+			for game, want := range tt.differences {
+				got := stats.CategoryDifferences[game]
+				if len(got) != len(want) {
+					t.Errorf("%s differences = %v, want %v", game, got, want)
+					continue
+				}
+				for category, difference := range want {
+					if math.Abs(got[category]-difference) > 1e-9 {
+						t.Errorf("%s %s = %v, want %v", game, category, got[category], difference)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestHeadToHeadLongestStreak(t *testing.T) {
+	scorecards := []documents.ScorecardDocumentCreate{
+		completedScorecard("s1", "azul", 1, map[string]float64{"alice": 10, "bob": 5}),
+		completedScorecard("s2", "azul", 2, map[string]float64{"alice": 5, "bob": 10}),
+		completedScorecard("s3", "azul", 3, map[string]float64{"alice": 5, "bob": 10}),
+		completedScorecard("s4", "azul", 4, map[string]float64{"alice": 5, "bob": 5}),
+		completedScorecard("s5", "azul", 5, map[string]float64{"alice": 10, "bob": 5}),
+	}
+
+	streak := headToHead(scorecards, "alice", "bob", HeadToHeadFilter{}).LongestStreak
+	if streak == nil || streak.Player != "bob" || streak.Length != 2 || streak.From.Day() != 2 || streak.To.Day() != 3 {
+		t.Errorf("LongestStreak = %+v, want bob winning on days 2 and 3", streak)
+	}
+}
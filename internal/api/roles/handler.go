@@ -0,0 +1,67 @@
+// Purpose:
+// Handles incoming HTTP requests and sends HTTP responses.
+// Acts as the controller layer in MVC terms.
+
+package roles
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/validation"
+)
+
+// RoleRequest is the body accepted when assigning a role.
+type RoleRequest struct {
+	Role auth.Role `json:"role" binding:"required"`
+}
+
+func HandleListRoles(store *auth.RoleStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		roles, err := store.List(c.Request.Context())
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to list roles", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"roles": roles})
+	}
+}
+
+func HandleSetRole(store *auth.RoleStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := auth.RequireUser(c)
+		if !ok {
+			return
+		}
+
+		var request RoleRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Abort(c, validation.Error(err))
+			return
+		}
+		if !request.Role.IsValid() {
+			apierror.Abort(c, apierror.Validation("unsupported_role", fmt.Sprintf("Unsupported role: %s", request.Role)))
+			return
+		}
+
+		assignment, err := store.Set(c.Request.Context(), c.Param("email"), request.Role, user.Email)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to set role", err))
+			return
+		}
+		c.JSON(http.StatusOK, assignment)
+	}
+}
+
+func HandleDeleteRole(store *auth.RoleStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := store.Delete(c.Request.Context(), c.Param("email")); err != nil {
+			apierror.Abort(c, apierror.Internal("failed to delete role", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Role deleted successfully"})
+	}
+}
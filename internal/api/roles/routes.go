@@ -0,0 +1,20 @@
+// Purpose:
+// Registers all HTTP routes related to managing user roles.
+// Keeps route definitions separate for clarity and modularity.
+
+package roles
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit"
+)
+
+func RegisterRoutes(rg *gin.RouterGroup, store *auth.RoleStore, authenticator *auth.Authenticator, limiter *ratelimit.Limiter) {
+	// role management is limited to admins
+	rolesAuthZAdminGroup := rg.Group("/roles", authenticator.RequireRole(auth.RoleAdmin), limiter.Limit())
+
+	rolesAuthZAdminGroup.GET("", HandleListRoles(store))
+	rolesAuthZAdminGroup.PUT("/:email", HandleSetRole(store))
+	rolesAuthZAdminGroup.DELETE("/:email", HandleDeleteRole(store))
+}
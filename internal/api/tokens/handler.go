@@ -0,0 +1,117 @@
+// Purpose:
+// Handles incoming HTTP requests and sends HTTP responses.
+// Acts as the controller layer in MVC terms.
+
+package tokens
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/validation"
+)
+
+const (
+	defaultTokenLifetimeDays = 90
+	maxTokenLifetimeDays     = 365
+)
+
+// TokenRequest is the body accepted when creating a token.
+type TokenRequest struct {
+	Name          string          `json:"name" binding:"required"`
+	Scope         auth.TokenScope `json:"scope" binding:"required"`
+	ExpiresInDays int             `json:"expires_in_days"`
+}
+
+// TokenResponse returns a new token. The token itself is never shown again.
+type TokenResponse struct {
+	Token string                 `json:"token"`
+	Info  *auth.APITokenDocument `json:"info"`
+}
+
+func HandleCreateToken(store *auth.TokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := auth.RequireUser(c)
+		if !ok {
+			return
+		}
+
+		// tokens cannot mint other tokens, so a leaked token cannot outlive its own expiry
+		if user.IsAPIToken() {
+			apierror.Abort(c, apierror.Forbidden("api_token_not_allowed", "API tokens cannot be used to create tokens"))
+			return
+		}
+
+		var request TokenRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Abort(c, validation.Error(err))
+			return
+		}
+		if !request.Scope.IsValid() {
+			apierror.Abort(c, apierror.Validation("unsupported_scope", fmt.Sprintf("Unsupported scope: %s", request.Scope)))
+			return
+		}
+		if !auth.GetRoleFromContext(c).Allows(request.Scope.Role()) {
+			apierror.Abort(c, apierror.Forbidden("scope_not_allowed", fmt.Sprintf("your role does not allow %s tokens", request.Scope)))
+			return
+		}
+
+		days := request.ExpiresInDays
+		if days == 0 {
+			days = defaultTokenLifetimeDays
+		}
+		if days < 0 || days > maxTokenLifetimeDays {
+			apierror.Abort(c, apierror.Validation("invalid_expiry", fmt.Sprintf("expires_in_days must be between 1 and %d", maxTokenLifetimeDays)))
+			return
+		}
+
+		token, info, err := store.Create(c.Request.Context(), user.Email, request.Name, request.Scope, time.Duration(days)*24*time.Hour)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to create token", err))
+			return
+		}
+		c.JSON(http.StatusOK, TokenResponse{Token: token, Info: info})
+	}
+}
+
+func HandleListTokens(store *auth.TokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := auth.RequireUser(c)
+		if !ok {
+			return
+		}
+
+		tokens, err := store.List(c.Request.Context(), user.Email)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to list tokens", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+	}
+}
+
+func HandleRevokeToken(store *auth.TokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := auth.RequireUser(c)
+		if !ok {
+			return
+		}
+
+		tokenId := c.Param("tokenId")
+		err := store.Revoke(c.Request.Context(), user.Email, tokenId)
+		if errors.Is(err, auth.ErrTokenNotFound) {
+			apierror.Abort(c, apierror.NotFound("token_not_found", fmt.Sprintf("Token with ID %s not found", tokenId)))
+			return
+		}
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to revoke token", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Token revoked successfully"})
+	}
+}
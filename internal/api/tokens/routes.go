@@ -0,0 +1,20 @@
+// Purpose:
+// Registers all HTTP routes related to personal API tokens.
+// Keeps route definitions separate for clarity and modularity.
+
+package tokens
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit"
+)
+
+func RegisterRoutes(rg *gin.RouterGroup, store *auth.TokenStore, authenticator *auth.Authenticator, limiter *ratelimit.Limiter) {
+	// any verified user can manage their own tokens
+	tokensAuthNGroup := rg.Group("/tokens", authenticator.RequireRole(auth.RoleViewer), limiter.Limit())
+
+	tokensAuthNGroup.POST("", HandleCreateToken(store))
+	tokensAuthNGroup.GET("", HandleListTokens(store))
+	tokensAuthNGroup.DELETE("/:tokenId", HandleRevokeToken(store))
+}
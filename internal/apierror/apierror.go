@@ -0,0 +1,239 @@
+// Purpose:
+// Typed domain errors and their RFC 7807 problem+json rendering.
+// Handlers report failures with Abort and the middleware writes exactly one response.
+
+package apierror
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+const renderedContextKey = "apierror.rendered"
+
+// Kind classifies an error and decides its status code.
+type Kind string
+
+const (
+	KindValidation      Kind = "validation_failed"
+	KindUnauthorized    Kind = "unauthorized"
+	KindForbidden       Kind = "forbidden"
+	KindNotFound        Kind = "not_found"
+	KindConflict        Kind = "conflict"
+	KindUnprocessable   Kind = "unprocessable"
+	KindPayloadTooLarge Kind = "payload_too_large"
+	KindRateLimited     Kind = "rate_limited"
+	KindUpstream        Kind = "upstream_failure"
+	KindInternal        Kind = "internal"
+)
+
+var statusByKind = map[Kind]int{
+	KindValidation:      http.StatusBadRequest,
+	KindUnauthorized:    http.StatusUnauthorized,
+	KindForbidden:       http.StatusForbidden,
+	KindNotFound:        http.StatusNotFound,
+	KindConflict:        http.StatusConflict,
+	KindUnprocessable:   http.StatusUnprocessableEntity,
+	KindPayloadTooLarge: http.StatusRequestEntityTooLarge,
+	KindRateLimited:     http.StatusTooManyRequests,
+	KindUpstream:        http.StatusBadGateway,
+	KindInternal:        http.StatusInternalServerError,
+}
+
+// Error is a failure a handler wants reported to the client. Code is a stable
+// machine readable identifier, Detail is shown to the client and Err, which
+// is never shown, keeps the underlying cause for logs.
+type Error struct {
+	Kind   Kind
+	Code   string
+	Detail string
+	Fields map[string]string
+	Err    error
+	// RetryAfter, when set, is sent as the Retry-After header
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status is the HTTP status code for the error's kind.
+func (e *Error) Status() int {
+	if status, ok := statusByKind[e.Kind]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
+
+func newError(kind Kind, code, detail string, err error) *Error {
+	if code == "" {
+		code = string(kind)
+	}
+	return &Error{Kind: kind, Code: code, Detail: detail, Err: err}
+}
+
+// Validation reports a request the client has to fix.
+func Validation(code, detail string) *Error {
+	return newError(KindValidation, code, detail, nil)
+}
+
+// Unauthorized reports a missing or invalid credential.
+func Unauthorized(code, detail string) *Error {
+	return newError(KindUnauthorized, code, detail, nil)
+}
+
+// Forbidden reports a caller that is known but not allowed.
+func Forbidden(code, detail string) *Error {
+	return newError(KindForbidden, code, detail, nil)
+}
+
+// NotFound reports a missing resource.
+func NotFound(code, detail string) *Error {
+	return newError(KindNotFound, code, detail, nil)
+}
+
+// Conflict reports a request that clashes with existing state.
+func Conflict(code, detail string) *Error {
+	return newError(KindConflict, code, detail, nil)
+}
+
+// Unprocessable reports a well formed request that cannot be applied.
+func Unprocessable(code, detail string) *Error {
+	return newError(KindUnprocessable, code, detail, nil)
+}
+
+// PayloadTooLarge reports a body over the configured limit.
+func PayloadTooLarge(code, detail string) *Error {
+	return newError(KindPayloadTooLarge, code, detail, nil)
+}
+
+// RateLimited reports a caller over one of its limits.
+func RateLimited(code, detail string) *Error {
+	return newError(KindRateLimited, code, detail, nil)
+}
+
+// Upstream reports a failure in a service we depend on, such as Gemini.
+func Upstream(code, detail string, err error) *Error {
+	return newError(KindUpstream, code, detail, err)
+}
+
+// Internal reports a failure on our side. err is logged, not returned.
+func Internal(detail string, err error) *Error {
+	return newError(KindInternal, "", detail, err)
+}
+
+// WithFields attaches per field messages, such as validation failures.
+func (e *Error) WithFields(fields map[string]string) *Error {
+	e.Fields = fields
+	return e
+}
+
+// WithRetryAfter tells the client how long to wait before trying again.
+func (e *Error) WithRetryAfter(d time.Duration) *Error {
+	e.RetryAfter = d
+	return e
+}
+
+// From returns err as an *Error, treating anything untyped as internal.
+func From(err error) *Error {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+	return Internal("internal server error", err)
+}
+
+// Abort records err for Middleware and stops the handler chain. Handlers
+// return right after calling it.
+func Abort(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Problem is an RFC 7807 body with our code and request ID extensions.
+type Problem struct {
+	Type      string            `json:"type"`
+	Title     string            `json:"title"`
+	Status    int               `json:"status"`
+	Detail    string            `json:"detail,omitempty"`
+	Instance  string            `json:"instance,omitempty"`
+	Code      string            `json:"code"`
+	RequestID string            `json:"request_id,omitempty"`
+	Errors    map[string]string `json:"errors,omitempty"`
+}
+
+// NewProblem builds the body for err as seen by the request in c.
+func NewProblem(c *gin.Context, err *Error) Problem {
+	status := err.Status()
+	return Problem{
+		Type:      "urn:owencrook:problem:" + err.Code,
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    err.Detail,
+		Instance:  c.Request.URL.Path,
+		Code:      err.Code,
+		RequestID: logging.RequestID(c),
+		Errors:    err.Fields,
+	}
+}
+
+// Write sends err as problem+json.
+func Write(c *gin.Context, err *Error) {
+	problem := NewProblem(c, err)
+	if err.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(err.RetryAfter.Seconds()))))
+	}
+	c.Header("Content-Type", ContentType)
+	c.JSON(problem.Status, problem)
+}
+
+// Middleware writes the last error recorded by the handler chain as the
+// response. If a handler already wrote a response the error is only logged,
+// so a request can never get two.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+		Render(c)
+	}
+}
+
+// Render writes the last recorded error now instead of when the request
+// unwinds to Middleware, for middleware that needs to see the final
+// response. Errors are only rendered once.
+func Render(c *gin.Context) {
+	if len(c.Errors) == 0 || c.GetBool(renderedContextKey) {
+		return
+	}
+	c.Set(renderedContextKey, true)
+
+	apiErr := From(c.Errors.Last().Err)
+	logger := logging.FromContext(c.Request.Context())
+	if apiErr.Status() >= http.StatusInternalServerError {
+		logger.Error("Request failed", "code", apiErr.Code, "error", apiErr)
+	} else {
+		logger.Info("Request rejected", "code", apiErr.Code, "detail", apiErr.Detail)
+	}
+
+	if c.Writer.Written() {
+		logger.Warn("Response already written, dropping error", "code", apiErr.Code)
+		return
+	}
+	Write(c, apiErr)
+}
@@ -2,67 +2,256 @@ package auth
 
 import (
 	"context"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/coreos/go-oidc/v3/oidc"
 )
 
-var (
-	verifier *oidc.IDTokenVerifier
-)
-
 // AuthenticatedUser holds the decoded user info from the token
 type AuthenticatedUser struct {
 	Email string
 	Sub   string
+
+	// TokenID and TokenScope are only set when the user authenticated with a
+	// personal API token rather than an OIDC ID token
+	TokenID    string
+	TokenScope TokenScope
+}
+
+// IsAPIToken reports whether the user authenticated with a personal API token.
+func (u *AuthenticatedUser) IsAPIToken() bool {
+	return u.TokenID != ""
+}
+
+// AuthenticatorConfig lists what an Authenticator should trust.
+type AuthenticatorConfig struct {
+	// Issuers are the OIDC issuer URLs whose ID tokens are accepted, e.g. https://accounts.google.com
+	Issuers []string
+	// Audiences are the client IDs an ID token may be issued for, across every issuer
+	Audiences []string
+	// Roles resolves a verified user's role
+	Roles *RoleStore
+	// Tokens, when set, enables personal API tokens
+	Tokens *TokenStore
+	// Sessions, when set, enables session cookies issued by the login flow
+	Sessions *SessionStore
+	// DevIssuer, when set, is trusted in addition to Issuers
+	DevIssuer *DevIssuer
+}
+
+// Authenticator verifies bearer tokens against every trusted OIDC issuer and,
+// optionally, personal API tokens. Verified tokens are cached so each token
+// costs at most one signature check or token lookup until it expires.
+type Authenticator struct {
+	verifiers map[string]*oidc.IDTokenVerifier
+	audiences []string
+	roles     *RoleStore
+	tokens    *TokenStore
+	sessions  *SessionStore
+	cache     *verifiedTokenCache
+}
+
+// NewAuthenticator discovers every configured issuer and builds a verifier for each.
+func NewAuthenticator(ctx context.Context, cfg AuthenticatorConfig) (*Authenticator, error) {
+	if cfg.Roles == nil {
+		return nil, errors.New("a role store is required")
+	}
+
+	a := &Authenticator{
+		verifiers: make(map[string]*oidc.IDTokenVerifier),
+		audiences: slices.Clone(cfg.Audiences),
+		roles:     cfg.Roles,
+		tokens:    cfg.Tokens,
+		sessions:  cfg.Sessions,
+		cache:     newVerifiedTokenCache(verifiedTokenCacheSize),
+	}
+
+	// the audience is checked against every configured audience once the token
+	// is verified, since go-oidc only supports a single client id per verifier
+	verifierConfig := &oidc.Config{SkipClientIDCheck: true}
+	for _, issuer := range cfg.Issuers {
+		provider, err := oidc.NewProvider(ctx, issuer)
+		if err != nil {
+			return nil, fmt.Errorf("oidc.NewProvider(%s): %w", issuer, err)
+		}
+		a.verifiers[issuer] = provider.Verifier(verifierConfig)
+	}
+
+	if cfg.DevIssuer != nil {
+		a.verifiers[cfg.DevIssuer.Issuer()] = cfg.DevIssuer.Verifier()
+		a.audiences = append(a.audiences, cfg.DevIssuer.Audience())
+	}
+
+	if len(a.verifiers) == 0 {
+		return nil, errors.New("at least one issuer is required")
+	}
+	if len(a.audiences) == 0 {
+		return nil, errors.New("at least one audience is required")
+	}
+	return a, nil
 }
 
-// Init sets up the OIDC token verifier.
-// projectClientID is your Google OAuth Client ID from the Google Cloud Console.
-func Init(ctx context.Context, projectClientID string) error {
-	provider, err := oidc.NewProvider(ctx, "https://accounts.google.com")
+// unverifiedIssuer reads the iss claim from a JWT without checking its
+// signature, only so the matching verifier can be picked.
+func unverifiedIssuer(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", errors.New("malformed jwt")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
 	if err != nil {
-		return fmt.Errorf("oidc.NewProvider: %w", err)
+		return "", fmt.Errorf("malformed jwt payload: %w", err)
 	}
+	var claims struct {
+		Issuer string `json:"iss"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("malformed jwt claims: %w", err)
+	}
+	return claims.Issuer, nil
+}
 
-	verifier = provider.Verifier(&oidc.Config{
-		ClientID: projectClientID,
-	})
+// Authenticate verifies a bearer token and returns the user it identifies,
+// answering from the cache when the token has already been verified.
+func (a *Authenticator) Authenticate(ctx context.Context, token string) (*AuthenticatedUser, error) {
+	key := hashToken(token)
+	if user, ok := a.cache.get(key); ok {
+		return user, nil
+	}
 
-	return nil
+	user, expiresAt, err := a.verify(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+	a.cache.put(key, user, expiresAt)
+	return user, nil
 }
 
-// GetUserFromRequest extracts and verifies the bearer token from an HTTP request.
-func GetUserFromRequest(r *http.Request) (*AuthenticatedUser, error) {
-	authHeader := r.Header.Get("Authorization")
-	if authHeader == "" {
-		return nil, errors.New("missing Authorization header")
+// verify checks a token against its issuer and returns the user along with
+// how long the result may be cached.
+func (a *Authenticator) verify(ctx context.Context, token string) (*AuthenticatedUser, time.Time, error) {
+	if strings.HasPrefix(token, TokenPrefix) {
+		if a.tokens == nil {
+			return nil, time.Time{}, errors.New("API tokens are not enabled")
+		}
+		user, err := a.tokens.Authenticate(ctx, token)
+		if err != nil {
+			return nil, time.Time{}, err
+		}
+		// api tokens can be revoked at any time, so they are only cached briefly
+		return user, time.Now().Add(apiTokenCacheTTL), nil
 	}
 
-	token := strings.TrimPrefix(authHeader, "Bearer ")
-	if token == authHeader {
-		return nil, errors.New("invalid Authorization header format")
+	idToken, err := a.verifyIDToken(ctx, token)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	user, err := userFromIDToken(idToken)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return user, idToken.Expiry, nil
+}
+
+// verifyIDToken checks an ID token's signature, expiry and audience against
+// the verifier of the issuer it claims to come from.
+func (a *Authenticator) verifyIDToken(ctx context.Context, token string) (*oidc.IDToken, error) {
+	issuer, err := unverifiedIssuer(token)
+	if err != nil {
+		return nil, err
+	}
+	verifier, ok := a.verifiers[issuer]
+	if !ok {
+		return nil, fmt.Errorf("untrusted issuer: %s", issuer)
 	}
 
-	ctx := r.Context()
 	idToken, err := verifier.Verify(ctx, token)
 	if err != nil {
 		return nil, fmt.Errorf("token verification failed: %w", err)
 	}
+	if !slices.ContainsFunc(idToken.Audience, func(aud string) bool { return slices.Contains(a.audiences, aud) }) {
+		return nil, fmt.Errorf("token audience %v is not accepted", idToken.Audience)
+	}
+	return idToken, nil
+}
 
+// userFromIDToken reads the user from a verified ID token. Roles are keyed by
+// email, so the issuer must vouch that the email belongs to the user, not
+// merely that the user typed it in.
+func userFromIDToken(idToken *oidc.IDToken) (*AuthenticatedUser, error) {
 	var claims struct {
-		Email string `json:"email"`
-		Sub   string `json:"sub"`
+		Email         string          `json:"email"`
+		EmailVerified json.RawMessage `json:"email_verified"`
+		Sub           string          `json:"sub"`
 	}
 	if err := idToken.Claims(&claims); err != nil {
 		return nil, fmt.Errorf("failed to parse claims: %w", err)
 	}
+	if claims.Email == "" {
+		return nil, errors.New("token has no email claim")
+	}
+	// some issuers send the flag as the string "true"
+	if verified := string(claims.EmailVerified); verified != "true" && verified != `"true"` {
+		return nil, fmt.Errorf("email %s is not verified by the issuer", claims.Email)
+	}
 
 	return &AuthenticatedUser{
 		Email: claims.Email,
 		Sub:   claims.Sub,
 	}, nil
 }
+
+// authenticateSession resolves a session cookie, answering from the cache
+// when the session has already been looked up.
+func (a *Authenticator) authenticateSession(ctx context.Context, sessionId string) (*AuthenticatedUser, error) {
+	if a.sessions == nil {
+		return nil, errors.New("session cookies are not enabled")
+	}
+
+	key := hashToken(sessionId)
+	if user, ok := a.cache.get(key); ok {
+		return user, nil
+	}
+
+	user, expiresAt, err := a.sessions.Authenticate(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
+	// sessions end on logout, so they are only cached briefly
+	if cacheUntil := time.Now().Add(sessionCacheTTL); cacheUntil.Before(expiresAt) {
+		expiresAt = cacheUntil
+	}
+	a.cache.put(key, user, expiresAt)
+	return user, nil
+}
+
+// forgetSession drops a session from the cache once it has been deleted.
+func (a *Authenticator) forgetSession(sessionId string) {
+	a.cache.delete(hashToken(sessionId))
+}
+
+// UserFromRequest extracts and verifies the bearer token from an HTTP request,
+// falling back to the session cookie when there is no Authorization header.
+func (a *Authenticator) UserFromRequest(r *http.Request) (*AuthenticatedUser, error) {
+	authHeader := r.Header.Get("Authorization")
+	if authHeader == "" {
+		if cookie, err := r.Cookie(SessionCookieName); err == nil && cookie.Value != "" {
+			return a.authenticateSession(r.Context(), cookie.Value)
+		}
+		return nil, errors.New("missing Authorization header or session cookie")
+	}
+
+	token := strings.TrimPrefix(authHeader, "Bearer ")
+	if token == authHeader {
+		return nil, errors.New("invalid Authorization header format")
+	}
+
+	return a.Authenticate(r.Context(), token)
+}
@@ -0,0 +1,70 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// verifiedTokenCacheSize caps how many verified tokens are remembered at once.
+	verifiedTokenCacheSize = 10000
+	// apiTokenCacheTTL bounds how long a revoked API token can keep working.
+	apiTokenCacheTTL = time.Minute
+)
+
+type cachedUser struct {
+	user      *AuthenticatedUser
+	expiresAt time.Time
+}
+
+// verifiedTokenCache remembers verified tokens, keyed by their hash so raw
+// tokens are never held in memory longer than a request, until they expire.
+type verifiedTokenCache struct {
+	mu      sync.Mutex
+	maxSize int
+	entries map[string]cachedUser
+}
+
+func newVerifiedTokenCache(maxSize int) *verifiedTokenCache {
+	return &verifiedTokenCache{maxSize: maxSize, entries: make(map[string]cachedUser)}
+}
+
+func (c *verifiedTokenCache) get(key string) (*AuthenticatedUser, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return entry.user, true
+}
+
+func (c *verifiedTokenCache) put(key string, user *AuthenticatedUser, expiresAt time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// make room by dropping expired entries, and start over if every entry is still live
+	if len(c.entries) >= c.maxSize {
+		now := time.Now()
+		for k, entry := range c.entries {
+			if now.After(entry.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= c.maxSize {
+			c.entries = make(map[string]cachedUser)
+		}
+	}
+	c.entries[key] = cachedUser{user: user, expiresAt: expiresAt}
+}
+
+func (c *verifiedTokenCache) delete(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, key)
+}
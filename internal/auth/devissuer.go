@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/google/uuid"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+)
+
+const (
+	// DevIssuerURL is the issuer claim of tokens minted by the development issuer.
+	DevIssuerURL = "urn:owencrook:dev-issuer"
+	// DevIssuerAudience is the audience claim of tokens minted by the development issuer.
+	DevIssuerAudience = "dev"
+)
+
+// DevIssuer signs ID tokens with a key generated at startup, so authenticated
+// routes can be exercised locally without a Google account. It must never be
+// enabled outside local development.
+type DevIssuer struct {
+	key   *rsa.PrivateKey
+	keyID string
+}
+
+func NewDevIssuer() (*DevIssuer, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate dev issuer key: %w", err)
+	}
+	return &DevIssuer{key: key, keyID: uuid.New().String()}, nil
+}
+
+func (d *DevIssuer) Issuer() string {
+	return DevIssuerURL
+}
+
+func (d *DevIssuer) Audience() string {
+	return DevIssuerAudience
+}
+
+// Verifier returns a verifier that accepts tokens signed by this issuer.
+func (d *DevIssuer) Verifier() *oidc.IDTokenVerifier {
+	keySet := &oidc.StaticKeySet{PublicKeys: []crypto.PublicKey{&d.key.PublicKey}}
+	return oidc.NewVerifier(DevIssuerURL, keySet, &oidc.Config{SkipClientIDCheck: true})
+}
+
+// Mint signs an ID token for email that expires after ttl.
+func (d *DevIssuer) Mint(email string, ttl time.Duration) (string, error) {
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: d.key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", d.keyID),
+	)
+	if err != nil {
+		return "", fmt.Errorf("failed to create signer: %w", err)
+	}
+
+	now := time.Now()
+	claims, err := json.Marshal(map[string]any{
+		"iss":            DevIssuerURL,
+		"aud":            DevIssuerAudience,
+		"sub":            "dev:" + email,
+		"email":          email,
+		"email_verified": true,
+		"iat":            now.Unix(),
+		"exp":            now.Add(ttl).Unix(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode claims: %w", err)
+	}
+
+	signed, err := signer.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign token: %w", err)
+	}
+	return signed.CompactSerialize()
+}
+
+// DevTokenRequest is the body accepted by the dev issuer's token endpoint.
+type DevTokenRequest struct {
+	Email      string `json:"email" binding:"required,email"`
+	TTLMinutes int    `json:"ttl_minutes"`
+}
+
+// HandleMintToken returns a handler that mints a token for any email. It is
+// only mounted when the dev issuer is enabled.
+func (d *DevIssuer) HandleMintToken() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var request DevTokenRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			apierror.Abort(c, apierror.Validation("invalid_body", "a JSON body with a valid email is required"))
+			return
+		}
+
+		ttl := time.Hour
+		if request.TTLMinutes > 0 {
+			ttl = time.Duration(request.TTLMinutes) * time.Minute
+		}
+
+		token, err := d.Mint(request.Email, ttl)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to mint token", err))
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"token": token, "expires_in": int(ttl.Seconds())})
+	}
+}
@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+)
+
+func TestDevIssuerTokenRoundTrip(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	issuer, err := NewDevIssuer()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// roles are preloaded so the store never reaches Firestore
+	roles := NewRoleStore(nil, nil, time.Hour)
+	roles.roles = map[string]Role{"contributor@example.com": RoleContributor}
+	roles.loadedAt = time.Now()
+	authenticator, err := NewAuthenticator(context.Background(), AuthenticatorConfig{Roles: roles, DevIssuer: issuer})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := gin.New()
+	r.Use(apierror.Middleware())
+	r.POST("/auth/dev/token", issuer.HandleMintToken())
+	r.GET("/viewer", authenticator.RequireRole(RoleViewer), func(c *gin.Context) {
+		user, _ := UserFromContext(c)
+		c.String(http.StatusOK, user.Email)
+	})
+	r.POST("/contributor", authenticator.RequireRole(RoleContributor), func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	mint := func(email string) string {
+		t.Helper()
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/auth/dev/token", strings.NewReader(`{"email":"`+email+`"}`)))
+		if w.Code != http.StatusOK {
+			t.Fatalf("minting a token for %s: status %d, body %s", email, w.Code, w.Body)
+		}
+		var response struct {
+			Token string `json:"token"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+			t.Fatal(err)
+		}
+		return response.Token
+	}
+	call := func(method, path, token string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, path, nil)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, request)
+		return w
+	}
+
+	viewer := mint("viewer@example.com")
+	contributor := mint("contributor@example.com")
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		token  string
+		want   int
+	}{
+		{"no token", http.MethodGet, "/viewer", "", http.StatusUnauthorized},
+		{"forged token", http.MethodGet, "/viewer", viewer + "x", http.StatusUnauthorized},
+		{"viewer reads", http.MethodGet, "/viewer", viewer, http.StatusOK},
+		{"viewer writes", http.MethodPost, "/contributor", viewer, http.StatusForbidden},
+		{"contributor reads", http.MethodGet, "/viewer", contributor, http.StatusOK},
+		{"contributor writes", http.MethodPost, "/contributor", contributor, http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := call(tt.method, tt.path, tt.token)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+
+	if w := call(http.MethodGet, "/viewer", viewer); w.Body.String() != "viewer@example.com" {
+		t.Errorf("viewer route saw user %q, want viewer@example.com", w.Body)
+	}
+}
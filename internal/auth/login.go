@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// loginCookieName carries the state, nonce and PKCE verifier between
+	// /auth/login and /auth/callback
+	loginCookieName = "oc_login"
+	loginCookieTTL  = 10 * time.Minute
+)
+
+// LoginConfig configures the browser login flow.
+type LoginConfig struct {
+	// Issuer is the OIDC issuer users log in with, e.g. https://accounts.google.com
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is this API's /auth/callback URL as registered with the issuer
+	RedirectURL string
+	// AllowedRedirectOrigins are the frontends a user may be sent back to after login
+	AllowedRedirectOrigins []string
+	// SecureCookies should only be false when serving over plain http locally
+	SecureCookies bool
+}
+
+// LoginFlow implements an OAuth2 authorization code flow with PKCE that ends
+// in an HttpOnly session cookie, so browsers never have to hold ID tokens.
+type LoginFlow struct {
+	// oauth is swapped whole when the client secret rotates
+	oauth                  atomic.Pointer[oauth2.Config]
+	authenticator          *Authenticator
+	sessions               *SessionStore
+	allowedRedirectOrigins []string
+	secureCookies          bool
+}
+
+// loginState is what the login cookie holds while the user is at the issuer.
+type loginState struct {
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	Redirect string `json:"redirect"`
+}
+
+func NewLoginFlow(ctx context.Context, cfg LoginConfig, authenticator *Authenticator, sessions *SessionStore) (*LoginFlow, error) {
+	provider, err := oidc.NewProvider(ctx, cfg.Issuer)
+	if err != nil {
+		return nil, fmt.Errorf("oidc.NewProvider(%s): %w", cfg.Issuer, err)
+	}
+
+	flow := &LoginFlow{
+		authenticator:          authenticator,
+		sessions:               sessions,
+		allowedRedirectOrigins: cfg.AllowedRedirectOrigins,
+		secureCookies:          cfg.SecureCookies,
+	}
+	flow.oauth.Store(&oauth2.Config{
+		ClientID:     cfg.ClientID,
+		ClientSecret: cfg.ClientSecret,
+		RedirectURL:  cfg.RedirectURL,
+		Endpoint:     provider.Endpoint(),
+		Scopes:       []string{oidc.ScopeOpenID, "email", "profile"},
+	})
+	return flow, nil
+}
+
+// SetClientSecret replaces the client secret used to exchange codes, for
+// rotated secrets.
+func (f *LoginFlow) SetClientSecret(secret string) {
+	updated := *f.oauth.Load()
+	updated.ClientSecret = secret
+	f.oauth.Store(&updated)
+}
+
+// RegisterRoutes mounts /auth/login, /auth/callback, /auth/logout and /auth/me,
+// running middleware ahead of each of them.
+func (f *LoginFlow) RegisterRoutes(r gin.IRouter, middleware ...gin.HandlerFunc) {
+	authGroup := r.Group("/auth", middleware...)
+	authGroup.GET("/login", f.HandleLogin())
+	authGroup.GET("/callback", f.HandleCallback())
+	authGroup.POST("/logout", f.HandleLogout())
+	authGroup.GET("/me", f.authenticator.RequireRole(RoleViewer), HandleMe())
+}
+
+func randomString() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// safeRedirect returns target if it is a relative path or points at an allowed
+// frontend, so the login flow cannot be used as an open redirect.
+func (f *LoginFlow) safeRedirect(target string) string {
+	if target == "" {
+		return "/"
+	}
+	if strings.HasPrefix(target, "/") && !strings.HasPrefix(target, "//") {
+		return target
+	}
+	parsed, err := url.Parse(target)
+	if err != nil {
+		return "/"
+	}
+	if slices.Contains(f.allowedRedirectOrigins, parsed.Scheme+"://"+parsed.Host) {
+		return target
+	}
+	return "/"
+}
+
+func (f *LoginFlow) setCookie(c *gin.Context, name, value string, maxAge int) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   f.secureCookies,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// HandleLogin sends the browser to the issuer, remembering where to return it to.
+func (f *LoginFlow) HandleLogin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		state, err := randomString()
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to start login", err))
+			return
+		}
+		nonce, err := randomString()
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to start login", err))
+			return
+		}
+
+		login := loginState{
+			State:    state,
+			Nonce:    nonce,
+			Verifier: oauth2.GenerateVerifier(),
+			Redirect: f.safeRedirect(c.Query("redirect")),
+		}
+		encoded, err := json.Marshal(login)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to start login", err))
+			return
+		}
+		f.setCookie(c, loginCookieName, base64.RawURLEncoding.EncodeToString(encoded), int(loginCookieTTL.Seconds()))
+
+		authURL := f.oauth.Load().AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(login.Verifier))
+		c.Redirect(http.StatusFound, authURL)
+	}
+}
+
+// HandleCallback exchanges the authorization code, verifies the ID token and
+// starts a session.
+func (f *LoginFlow) HandleCallback() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// the login cookie is single use
+		cookie, err := c.Cookie(loginCookieName)
+		f.setCookie(c, loginCookieName, "", -1)
+		if err != nil {
+			apierror.Abort(c, apierror.Validation("login_expired", "login expired, please try again"))
+			return
+		}
+		decoded, err := base64.RawURLEncoding.DecodeString(cookie)
+		if err != nil {
+			apierror.Abort(c, apierror.Validation("invalid_login_state", "invalid login state"))
+			return
+		}
+		var login loginState
+		if err := json.Unmarshal(decoded, &login); err != nil {
+			apierror.Abort(c, apierror.Validation("invalid_login_state", "invalid login state"))
+			return
+		}
+
+		if errParam := c.Query("error"); errParam != "" {
+			apierror.Abort(c, apierror.Unauthorized("login_failed", fmt.Sprintf("login failed: %s", errParam)))
+			return
+		}
+		if c.Query("state") == "" || c.Query("state") != login.State {
+			apierror.Abort(c, apierror.Validation("invalid_login_state", "login state mismatch"))
+			return
+		}
+
+		token, err := f.oauth.Load().Exchange(c.Request.Context(), c.Query("code"), oauth2.VerifierOption(login.Verifier))
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("Error exchanging authorization code", "error", err)
+			apierror.Abort(c, apierror.Unauthorized("login_failed", "failed to exchange authorization code"))
+			return
+		}
+		rawIDToken, ok := token.Extra("id_token").(string)
+		if !ok {
+			apierror.Abort(c, apierror.Unauthorized("login_failed", "no id token in token response"))
+			return
+		}
+
+		idToken, err := f.authenticator.verifyIDToken(c.Request.Context(), rawIDToken)
+		if err != nil {
+			logging.FromContext(c.Request.Context()).Error("Error verifying ID token", "error", err)
+			apierror.Abort(c, apierror.Unauthorized("", "unauthorized"))
+			return
+		}
+		if idToken.Nonce != login.Nonce {
+			apierror.Abort(c, apierror.Unauthorized("login_failed", "login nonce mismatch"))
+			return
+		}
+		user, err := userFromIDToken(idToken)
+		if err != nil {
+			apierror.Abort(c, apierror.Unauthorized("", "unauthorized"))
+			return
+		}
+
+		sessionId, expiresAt, err := f.sessions.Create(c.Request.Context(), user)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to start session", err))
+			return
+		}
+		f.setCookie(c, SessionCookieName, sessionId, int(time.Until(expiresAt).Seconds()))
+
+		c.Redirect(http.StatusFound, login.Redirect)
+	}
+}
+
+// HandleLogout ends the current session and clears the cookie.
+func (f *LoginFlow) HandleLogout() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sessionId, err := c.Cookie(SessionCookieName); err == nil && sessionId != "" {
+			if err := f.sessions.Delete(c.Request.Context(), sessionId); err != nil {
+				apierror.Abort(c, apierror.Internal("failed to end session", err))
+				return
+			}
+			f.authenticator.forgetSession(sessionId)
+		}
+		f.setCookie(c, SessionCookieName, "", -1)
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
+	}
+}
+
+// HandleMe returns the current user and role, so the frontend can tell whether
+// its session cookie is still valid.
+func HandleMe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := RequireUser(c)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"email": user.Email, "role": GetRoleFromContext(c)})
+	}
+}
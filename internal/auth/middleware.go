@@ -1,40 +1,83 @@
 package auth
 
 import (
-	"fmt"
-	"net/http"
-
 	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+)
+
+// context keys RequireRole stores the verified user and role under
+const (
+	userContextKey = "auth.user"
+	roleContextKey = "auth.role"
 )
 
-// RequireAuth returns a Gin middleware that enforces:
-// 1. Valid Google-issued token
-// 2. Email must be in allowedEmails (optional, pass nil to skip check)
-func RequireAuth(allowedEmails []string) gin.HandlerFunc {
+// RequireRole returns a Gin middleware that enforces:
+// 1. Valid token from a trusted issuer, or a personal API token
+// 2. The user's role grants at least minimum
+func (a *Authenticator) RequireRole(minimum Role) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		user, err := GetUserFromRequest(c.Request)
+		user, err := a.UserFromRequest(c.Request)
 		if err != nil {
-			fmt.Println("error: ", err.Error())
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			logging.FromContext(c.Request.Context()).Warn("Authentication failed", "error", err)
+			apierror.Abort(c, apierror.Unauthorized("", "unauthorized"))
 			return
 		}
 
-		if len(allowedEmails) > 0 && !contains(allowedEmails, user.Email) {
-			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+		role, err := a.roles.RoleFor(c.Request.Context(), user.Email)
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("unable to load role", err))
 			return
 		}
 
-		// Store user info in context for downstream use
-		c.Set("user", user)
+		// an API token can never act above its scope, whatever its owner's role
+		if user.IsAPIToken() && !user.TokenScope.Role().Allows(role) {
+			role = user.TokenScope.Role()
+		}
+
+		if !role.Allows(minimum) {
+			apierror.Abort(c, apierror.Forbidden("", "forbidden"))
+			return
+		}
+
+		// Store user info in context for downstream use, so handlers never verify the token again
+		c.Set(userContextKey, user)
+		c.Set(roleContextKey, role)
+		// later log lines for this request carry who made it
+		c.Request = c.Request.WithContext(logging.With(c.Request.Context(), "user", user.Email))
 		c.Next()
 	}
 }
 
-func contains(slice []string, val string) bool {
-	for _, v := range slice {
-		if v == val {
-			return true
+// UserFromContext returns the user RequireRole verified for the current request.
+// It reports false on routes that are not behind RequireRole.
+func UserFromContext(c *gin.Context) (*AuthenticatedUser, bool) {
+	value, ok := c.Get(userContextKey)
+	if !ok {
+		return nil, false
+	}
+	user, ok := value.(*AuthenticatedUser)
+	return user, ok && user != nil
+}
+
+// RequireUser returns the user RequireRole verified for the current request,
+// aborting with 401 when there is none, so handlers can simply return.
+func RequireUser(c *gin.Context) (*AuthenticatedUser, bool) {
+	user, ok := UserFromContext(c)
+	if !ok {
+		apierror.Abort(c, apierror.Unauthorized("", "unauthorized"))
+		return nil, false
+	}
+	return user, true
+}
+
+// GetRoleFromContext returns the role RequireRole stored for the current request,
+// falling back to viewer if none was stored.
+func GetRoleFromContext(c *gin.Context) Role {
+	if role, ok := c.Get(roleContextKey); ok {
+		if r, ok := role.(Role); ok {
+			return r
 		}
 	}
-	return false
+	return RoleViewer
 }
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/firestore"
+)
+
+const rolesCollection = "user-roles"
+
+// Role is the level of access a user has. Each role includes everything the
+// roles below it can do.
+type Role string
+
+const (
+	// RoleViewer can read everything. Any verified user without a stored role is a viewer.
+	RoleViewer Role = "viewer"
+	// RoleContributor can also create scorecards and edit the ones they created.
+	RoleContributor Role = "contributor"
+	// RoleAdmin can do everything, including managing roles.
+	RoleAdmin Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer:      1,
+	RoleContributor: 2,
+	RoleAdmin:       3,
+}
+
+// IsValid reports whether r is one of the known roles.
+func (r Role) IsValid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// Allows reports whether r grants at least the access of required.
+func (r Role) Allows(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// UserRoleDocument is a role assignment as stored in Firestore, keyed by email.
+type UserRoleDocument struct {
+	Email     string    `firestore:"email" json:"email"`
+	Role      Role      `firestore:"role" json:"role"`
+	UpdatedBy string    `firestore:"updated_by" json:"updated_by"`
+	UpdatedAt time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// RoleStore reads role assignments from Firestore and caches them for a short
+// time so that role changes take effect without a redeploy.
+type RoleStore struct {
+	client          *firestore.Client
+	bootstrapAdmins map[string]bool
+	ttl             time.Duration
+
+	mu       sync.Mutex
+	roles    map[string]Role
+	loadedAt time.Time
+}
+
+// NewRoleStore creates a RoleStore. bootstrapAdmins are always treated as
+// admins, so there is someone able to assign the first roles.
+func NewRoleStore(client *firestore.Client, bootstrapAdmins []string, ttl time.Duration) *RoleStore {
+	admins := make(map[string]bool, len(bootstrapAdmins))
+	for _, email := range bootstrapAdmins {
+		if email = normalizeEmail(email); email != "" {
+			admins[email] = true
+		}
+	}
+	return &RoleStore{client: client, bootstrapAdmins: admins, ttl: ttl}
+}
+
+func normalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// RoleFor returns the role of the user with the given email.
+func (s *RoleStore) RoleFor(ctx context.Context, email string) (Role, error) {
+	email = normalizeEmail(email)
+	if s.bootstrapAdmins[email] {
+		return RoleAdmin, nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.roles == nil || time.Since(s.loadedAt) > s.ttl {
+		if err := s.load(ctx); err != nil {
+			return "", err
+		}
+	}
+	if role, ok := s.roles[email]; ok {
+		return role, nil
+	}
+	return RoleViewer, nil
+}
+
+// load replaces the cache with every stored role. Callers must hold s.mu.
+func (s *RoleStore) load(ctx context.Context) error {
+	assignments, err := s.List(ctx)
+	if err != nil {
+		return err
+	}
+	roles := make(map[string]Role, len(assignments))
+	for _, assignment := range assignments {
+		roles[assignment.Email] = assignment.Role
+	}
+	s.roles = roles
+	s.loadedAt = time.Now()
+	return nil
+}
+
+func (s *RoleStore) invalidate() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.roles = nil
+}
+
+// List returns every stored role assignment.
+func (s *RoleStore) List(ctx context.Context) ([]UserRoleDocument, error) {
+	snapshots, err := s.client.Collection(rolesCollection).OrderBy("email", firestore.Asc).Documents(ctx).GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	assignments := make([]UserRoleDocument, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var assignment UserRoleDocument
+		if err := snapshot.DataTo(&assignment); err != nil {
+			return nil, fmt.Errorf("failed to decode role %s: %w", snapshot.Ref.ID, err)
+		}
+		assignments = append(assignments, assignment)
+	}
+	return assignments, nil
+}
+
+// Set assigns a role to a user.
+func (s *RoleStore) Set(ctx context.Context, email string, role Role, updatedBy string) (*UserRoleDocument, error) {
+	if !role.IsValid() {
+		return nil, fmt.Errorf("unknown role: %s", role)
+	}
+	assignment := &UserRoleDocument{
+		Email:     normalizeEmail(email),
+		Role:      role,
+		UpdatedBy: updatedBy,
+		UpdatedAt: time.Now().In(time.UTC),
+	}
+	if _, err := s.client.Collection(rolesCollection).Doc(assignment.Email).Set(ctx, assignment); err != nil {
+		return nil, fmt.Errorf("failed to save role: %w", err)
+	}
+	s.invalidate()
+	return assignment, nil
+}
+
+// Delete removes a user's role assignment, returning them to viewer.
+func (s *RoleStore) Delete(ctx context.Context, email string) error {
+	if _, err := s.client.Collection(rolesCollection).Doc(normalizeEmail(email)).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	s.invalidate()
+	return nil
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const sessionsCollection = "auth-sessions"
+
+// SessionCookieName is the cookie that carries a browser session id.
+const SessionCookieName = "oc_session"
+
+// sessionCacheTTL bounds how long a session can keep working on another
+// instance after the user logs out.
+const sessionCacheTTL = time.Minute
+
+// SessionDocument is a browser login as stored in Firestore. Sessions are
+// stored under a hash of their id, so a database leak cannot be replayed.
+type SessionDocument struct {
+	Email     string    `firestore:"email"`
+	Sub       string    `firestore:"sub"`
+	ExpiresAt time.Time `firestore:"expires_at"`
+	CreatedAt time.Time `firestore:"created_at"`
+}
+
+// SessionStore keeps server-side browser sessions created by the login flow.
+type SessionStore struct {
+	client *firestore.Client
+	ttl    time.Duration
+}
+
+func NewSessionStore(client *firestore.Client, ttl time.Duration) *SessionStore {
+	return &SessionStore{client: client, ttl: ttl}
+}
+
+// Create starts a session for user and returns the id to put in the cookie.
+func (s *SessionStore) Create(ctx context.Context, user *AuthenticatedUser) (string, time.Time, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to generate session id: %w", err)
+	}
+	sessionId := base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now().In(time.UTC)
+	session := SessionDocument{
+		Email:     normalizeEmail(user.Email),
+		Sub:       user.Sub,
+		ExpiresAt: now.Add(s.ttl),
+		CreatedAt: now,
+	}
+	if _, err := s.client.Collection(sessionsCollection).Doc(hashToken(sessionId)).Set(ctx, session); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to save session: %w", err)
+	}
+	return sessionId, session.ExpiresAt, nil
+}
+
+// Authenticate resolves a session id to the user who logged in, along with
+// when the session expires.
+func (s *SessionStore) Authenticate(ctx context.Context, sessionId string) (*AuthenticatedUser, time.Time, error) {
+	snapshot, err := s.client.Collection(sessionsCollection).Doc(hashToken(sessionId)).Get(ctx)
+	if status.Code(err) == codes.NotFound {
+		return nil, time.Time{}, errors.New("unknown session")
+	}
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to look up session: %w", err)
+	}
+
+	var session SessionDocument
+	if err := snapshot.DataTo(&session); err != nil {
+		return nil, time.Time{}, fmt.Errorf("failed to decode session: %w", err)
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return nil, time.Time{}, errors.New("session has expired")
+	}
+
+	return &AuthenticatedUser{Email: session.Email, Sub: session.Sub}, session.ExpiresAt, nil
+}
+
+// Delete ends a session.
+func (s *SessionStore) Delete(ctx context.Context, sessionId string) error {
+	if _, err := s.client.Collection(sessionsCollection).Doc(hashToken(sessionId)).Delete(ctx); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,200 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/google/uuid"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+)
+
+const (
+	tokensCollection = "api-tokens"
+
+	// TokenPrefix marks a bearer token as a personal API token rather than an OIDC ID token.
+	TokenPrefix = "oct_"
+
+	// lastUsedResolution limits how often last_used_at is written for a busy token.
+	lastUsedResolution = time.Minute
+)
+
+// ErrTokenNotFound is returned when a token does not exist or belongs to another user.
+var ErrTokenNotFound = errors.New("token not found")
+
+// TokenScope limits what a personal API token can do, regardless of the
+// owner's role.
+type TokenScope string
+
+const (
+	ScopeRead  TokenScope = "read"
+	ScopeWrite TokenScope = "write"
+	ScopeAdmin TokenScope = "admin"
+)
+
+var scopeRoles = map[TokenScope]Role{
+	ScopeRead:  RoleViewer,
+	ScopeWrite: RoleContributor,
+	ScopeAdmin: RoleAdmin,
+}
+
+// IsValid reports whether s is one of the known scopes.
+func (s TokenScope) IsValid() bool {
+	_, ok := scopeRoles[s]
+	return ok
+}
+
+// Role returns the highest role a token with this scope can act as.
+func (s TokenScope) Role() Role {
+	return scopeRoles[s]
+}
+
+// APITokenDocument is a personal API token as stored in Firestore. Only a hash
+// of the token is kept, the token itself is shown once when it is created.
+type APITokenDocument struct {
+	ID         string     `firestore:"id" json:"id"`
+	Name       string     `firestore:"name" json:"name"`
+	Email      string     `firestore:"email" json:"email"`
+	Scope      TokenScope `firestore:"scope" json:"scope"`
+	TokenHash  string     `firestore:"token_hash" json:"-"`
+	ExpiresAt  time.Time  `firestore:"expires_at" json:"expires_at"`
+	RevokedAt  *time.Time `firestore:"revoked_at" json:"revoked_at"`
+	LastUsedAt *time.Time `firestore:"last_used_at" json:"last_used_at"`
+	CreatedAt  time.Time  `firestore:"created_at" json:"created_at"`
+}
+
+// TokenStore issues, verifies and revokes personal API tokens.
+type TokenStore struct {
+	client *firestore.Client
+}
+
+func NewTokenStore(client *firestore.Client) *TokenStore {
+	return &TokenStore{client: client}
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// Create issues a new token for email and returns it alongside its stored record.
+func (s *TokenStore) Create(ctx context.Context, email, name string, scope TokenScope, ttl time.Duration) (string, *APITokenDocument, error) {
+	if !scope.IsValid() {
+		return "", nil, fmt.Errorf("unknown scope: %s", scope)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	token := TokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	now := time.Now().In(time.UTC)
+	document := &APITokenDocument{
+		ID:        uuid.New().String(),
+		Name:      name,
+		Email:     normalizeEmail(email),
+		Scope:     scope,
+		TokenHash: hashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+	if _, err := s.client.Collection(tokensCollection).Doc(document.ID).Set(ctx, document); err != nil {
+		return "", nil, fmt.Errorf("failed to save token: %w", err)
+	}
+	return token, document, nil
+}
+
+// List returns every token issued to email, including expired and revoked ones.
+func (s *TokenStore) List(ctx context.Context, email string) ([]APITokenDocument, error) {
+	snapshots, err := s.client.Collection(tokensCollection).
+		Where("email", "==", normalizeEmail(email)).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list tokens: %w", err)
+	}
+
+	tokens := make([]APITokenDocument, 0, len(snapshots))
+	for _, snapshot := range snapshots {
+		var token APITokenDocument
+		if err := snapshot.DataTo(&token); err != nil {
+			return nil, fmt.Errorf("failed to decode token %s: %w", snapshot.Ref.ID, err)
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, nil
+}
+
+// Revoke marks one of email's tokens as revoked so it can no longer be used.
+func (s *TokenStore) Revoke(ctx context.Context, email, tokenId string) error {
+	reference := s.client.Collection(tokensCollection).Doc(tokenId)
+	snapshot, err := reference.Get(ctx)
+	if err != nil || !snapshot.Exists() {
+		return ErrTokenNotFound
+	}
+	var token APITokenDocument
+	if err := snapshot.DataTo(&token); err != nil {
+		return fmt.Errorf("failed to decode token: %w", err)
+	}
+	if token.Email != normalizeEmail(email) {
+		return ErrTokenNotFound
+	}
+
+	_, err = reference.Update(ctx, []firestore.Update{{Path: "revoked_at", Value: time.Now().In(time.UTC)}})
+	return err
+}
+
+// Authenticate resolves a personal API token to the user it was issued to.
+func (s *TokenStore) Authenticate(ctx context.Context, token string) (*AuthenticatedUser, error) {
+	if !strings.HasPrefix(token, TokenPrefix) {
+		return nil, errors.New("not an API token")
+	}
+
+	snapshots, err := s.client.Collection(tokensCollection).
+		Where("token_hash", "==", hashToken(token)).
+		Limit(1).
+		Documents(ctx).
+		GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up token: %w", err)
+	}
+	if len(snapshots) == 0 {
+		return nil, errors.New("unknown API token")
+	}
+
+	var document APITokenDocument
+	if err := snapshots[0].DataTo(&document); err != nil {
+		return nil, fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	now := time.Now().In(time.UTC)
+	if document.RevokedAt != nil {
+		return nil, errors.New("API token has been revoked")
+	}
+	if now.After(document.ExpiresAt) {
+		return nil, errors.New("API token has expired")
+	}
+
+	// record usage, at most once per lastUsedResolution to avoid a write per request
+	if document.LastUsedAt == nil || now.Sub(*document.LastUsedAt) > lastUsedResolution {
+		_, err := snapshots[0].Ref.Update(ctx, []firestore.Update{{Path: "last_used_at", Value: now}})
+		if err != nil {
+			logging.FromContext(ctx).Warn("Failed to record API token use", "error", err)
+		}
+	}
+
+	return &AuthenticatedUser{
+		Email:      document.Email,
+		Sub:        "token:" + document.ID,
+		TokenID:    document.ID,
+		TokenScope: document.Scope,
+	}, nil
+}
@@ -1,11 +1,21 @@
 package config
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
+	"net"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/errorreport/dsn"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit/limit"
 )
 
 type Config struct {
@@ -13,71 +23,277 @@ type Config struct {
 	FirestoreDatabaseID string
 	GeminiToken         string
 	Environment         string
+	LogLevel            slog.Level
 	GoogleClientID      string
 	GoogleClientSecret  string
-	AdminEmails         string
+	AdminEmails         []string
+	OIDCIssuers         []string
+	OIDCAudiences       []string
+	DevIssuerEnabled    bool
+	GoogleRedirectURL   string
+	AuthRedirectOrigins []string
+	RateLimitDefault    limit.Limit
+	RateLimitRoutes     map[string]limit.Limit
+	RateLimitIP         limit.Limit
+	LLMDailyBudget      int
+	CORSAllowOrigins    []string
+	CORSAllowMethods    []string
+	CORSAllowHeaders    []string
+	ListenHost          string
+	Port                int
+	// TrustedProxies are the IPs or CIDRs whose X-Forwarded-For is believed
+	// when finding the client IP. When empty the connection's address is used.
+	TrustedProxies    []string
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+	MaxBodyBytes      int64
+	ReadinessCacheTTL time.Duration
+	ReadinessTimeout  time.Duration
+	ReadinessLLMPing  bool
+	// ShutdownDrainDelay is how long requests are still served after shutdown
+	// starts, with health checks failing, so traffic moves elsewhere first.
+	ShutdownDrainDelay time.Duration
+	// SecretRefreshInterval is how often secret references are re-resolved
+	// to pick up rotations. Zero disables refreshing.
+	SecretRefreshInterval time.Duration
+	// TraceExporter is none, stdout or otlp. The otlp endpoint is read from
+	// the standard OTEL_EXPORTER_OTLP_ENDPOINT variable.
+	TraceExporter    string
+	TraceSampleRatio float64
+	// MetricsToken, when set, must be sent as a bearer token to scrape /metrics.
+	MetricsToken string
+	// SentryDSN, when set, forwards recovered panics to a Sentry-compatible
+	// endpoint in addition to the logs.
+	SentryDSN *dsn.DSN
+	// IdempotencyTTL is how long a response is replayed for a repeated
+	// Idempotency-Key.
+	IdempotencyTTL time.Duration
+	// APIV1Sunset is the date announced to clients of deprecated v1 routes
+	// after which those routes may be removed.
+	APIV1Sunset time.Time
+
+	secretResolver SecretResolver
+	secrets        map[string]resolvedSecret
+}
+
+// LoadConfig reads settings from the config file at path, falling back to
+// CONFIG_FILE when path is empty, with environment variables taking precedence
+// over the file. Any value may be a file:// or secretmanager:// reference.
+// Every problem found is returned together in one error.
+func LoadConfig(path string) (*Config, error) {
+	return LoadConfigWithResolver(path, DefaultSecretResolver())
 }
 
-// LoadConfig reads environment variables into a Config struct.
-func LoadConfig() *Config {
+// LoadConfigWithResolver is LoadConfig with the secret backends swapped out,
+// e.g. for a MemoryResolver in tests.
+func LoadConfigWithResolver(path string, resolver SchemeResolver) (*Config, error) {
 
 	err := godotenv.Load()
 	if err != nil {
 		log.Println("No .env file found, continuing with existing env vars")
 	}
 
+	if path == "" {
+		path = os.Getenv("CONFIG_FILE")
+	}
+	src, err := newSource(path, resolver)
+	if src == nil {
+		return nil, err
+	}
+	errs := []error{err}
+
 	cfg := &Config{
-		AdminEmails:         getEnv("ADMIN_EMAILS", ""),
-		GCPProjectID:        getEnv("GCP_PROJECT_ID", ""),
-		FirestoreDatabaseID: getEnv("FIRESTORE_DATABASE_ID", ""),
-		GeminiToken:         getEnv("GEMINI_API_KEY", ""),
-		Environment:         getEnv("ENVIRONMENT", "LOCAL"),
-		GoogleClientID:      getEnv("GOOGLE_CLIENT_ID", ""),
-		GoogleClientSecret:  getEnv("GOOGLE_CLIENT_SECRET", ""),
+		AdminEmails:           src.list("ADMIN_EMAILS", nil),
+		GCPProjectID:          src.string("GCP_PROJECT_ID", ""),
+		FirestoreDatabaseID:   src.string("FIRESTORE_DATABASE_ID", ""),
+		GeminiToken:           src.string("GEMINI_API_KEY", ""),
+		Environment:           src.string("ENVIRONMENT", "LOCAL"),
+		LogLevel:              src.level("LOG_LEVEL", slog.LevelInfo),
+		GoogleClientID:        src.string("GOOGLE_CLIENT_ID", ""),
+		GoogleClientSecret:    src.string("GOOGLE_CLIENT_SECRET", ""),
+		OIDCIssuers:           src.list("OIDC_ISSUERS", []string{"https://accounts.google.com"}),
+		OIDCAudiences:         src.list("OIDC_AUDIENCES", nil),
+		DevIssuerEnabled:      src.bool("DEV_ISSUER_ENABLED", false),
+		GoogleRedirectURL:     src.string("GOOGLE_REDIRECT_URL", "http://localhost:8080/auth/callback"),
+		AuthRedirectOrigins:   src.list("AUTH_REDIRECT_ORIGINS", nil),
+		RateLimitDefault:      src.rateLimit("RATE_LIMIT_DEFAULT", "120/m"),
+		RateLimitRoutes:       src.routeLimits("RATE_LIMIT_ROUTES", "POST /api/v1/board-game-tracker/parse-score-card-from-image/:game=10/m;POST /api/v1/board-game-tracker/parse-score-card/:game=10/m;POST /api/v2/parses=10/m"),
+		RateLimitIP:           src.rateLimit("RATE_LIMIT_IP", "300/m"),
+		LLMDailyBudget:        src.int("LLM_DAILY_BUDGET", 50),
+		CORSAllowOrigins:      src.list("CORS_ALLOW_ORIGINS", []string{"http://localhost:3000", "https://owencrook.com"}),
+		CORSAllowMethods:      src.list("CORS_ALLOW_METHODS", []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}),
+		CORSAllowHeaders:      src.list("CORS_ALLOW_HEADERS", []string{"Origin", "Content-Type", "Accept", "Authorization", "Idempotency-Key"}),
+		ListenHost:            src.string("HOST", ""),
+		Port:                  src.int("PORT", 8080),
+		TrustedProxies:        src.list("TRUSTED_PROXIES", nil),
+		ReadTimeout:           src.duration("READ_TIMEOUT", 30*time.Second),
+		WriteTimeout:          src.duration("WRITE_TIMEOUT", 2*time.Minute),
+		IdleTimeout:           src.duration("IDLE_TIMEOUT", 2*time.Minute),
+		ShutdownTimeout:       src.duration("SHUTDOWN_TIMEOUT", 10*time.Second),
+		ShutdownDrainDelay:    src.duration("SHUTDOWN_DRAIN_DELAY", 3*time.Second),
+		MaxBodyBytes:          int64(src.int("MAX_BODY_BYTES", 12<<20)),
+		ReadinessCacheTTL:     src.duration("READINESS_CACHE_TTL", 10*time.Second),
+		ReadinessTimeout:      src.duration("READINESS_TIMEOUT", 3*time.Second),
+		ReadinessLLMPing:      src.bool("READINESS_LLM_PING", false),
+		SecretRefreshInterval: src.duration("SECRET_REFRESH_INTERVAL", 0),
+		TraceExporter:         src.string("TRACE_EXPORTER", "none"),
+		TraceSampleRatio:      src.float("TRACE_SAMPLE_RATIO", 1),
+		MetricsToken:          src.string("METRICS_TOKEN", ""),
+		SentryDSN:             src.reportDSN("SENTRY_DSN"),
+		IdempotencyTTL:        src.duration("IDEMPOTENCY_TTL", 24*time.Hour),
+		APIV1Sunset:           src.date("API_V1_SUNSET", time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)),
+		secretResolver:        resolver,
+		secrets:               src.secrets,
 	}
+	credentials := src.string("GOOGLE_APPLICATION_CREDENTIALS", "")
 
-	if cfg.AdminEmails == "" {
-		log.Fatal("ADMIN_EMAILS is required")
+	errs = append(errs, src.errs...)
+	for _, key := range src.unknownKeys() {
+		errs = append(errs, fmt.Errorf("unknown config file key %s", key))
 	}
 
-	if cfg.GCPProjectID == "" {
-		log.Fatal("GCP_PROJECT_ID is required")
+	// audiences default to our own client id, and logins may return to any
+	// origin the frontend is served from
+	if len(cfg.OIDCAudiences) == 0 && cfg.GoogleClientID != "" {
+		cfg.OIDCAudiences = []string{cfg.GoogleClientID}
+	}
+	if len(cfg.AuthRedirectOrigins) == 0 {
+		cfg.AuthRedirectOrigins = slices.Clone(cfg.CORSAllowOrigins)
 	}
 
-	if cfg.FirestoreDatabaseID == "" {
-		log.Fatal("FirestoreDatabaseID is required")
+	errs = append(errs, cfg.validate()...)
+	if cfg.Environment == "LOCAL" && credentials == "" {
+		errs = append(errs, errors.New("GOOGLE_APPLICATION_CREDENTIALS is required for local development"))
 	}
 
-	if cfg.GeminiToken == "" {
-		log.Fatal("GEMINI_API_KEY is required")
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
 	}
 
-	if cfg.GoogleClientSecret == "" {
-		log.Fatal("GOOGLE_CLIENT_ID is required")
+	if len(cfg.AdminEmails) == 0 {
+		log.Println("ADMIN_EMAILS is empty, roles must already be assigned in Firestore")
 	}
+	return cfg, nil
+}
 
-	if cfg.FirestoreDatabaseID == "" {
-		log.Fatal("GOOGLE_CLIENT_SECRET is required")
+func (cfg *Config) validate() []error {
+	var errs []error
+	required := []struct{ key, value string }{
+		{"GCP_PROJECT_ID", cfg.GCPProjectID},
+		{"FIRESTORE_DATABASE_ID", cfg.FirestoreDatabaseID},
+		{"GEMINI_API_KEY", cfg.GeminiToken},
+		{"GOOGLE_CLIENT_ID", cfg.GoogleClientID},
+		{"GOOGLE_CLIENT_SECRET", cfg.GoogleClientSecret},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", r.key))
+		}
 	}
 
-	if cfg.Environment == "LOCAL" && getEnv("GOOGLE_APPLICATION_CREDENTIALS", "") == "" {
-		log.Fatal("GOOGLE_APPLICATION_CREDENTIALS required for local development")
+	if cfg.DevIssuerEnabled && cfg.Environment != "LOCAL" {
+		errs = append(errs, errors.New("DEV_ISSUER_ENABLED is only allowed when ENVIRONMENT is LOCAL"))
 	}
 
-	return cfg
-}
+	if len(cfg.OIDCIssuers) == 0 {
+		errs = append(errs, errors.New("OIDC_ISSUERS must list at least one issuer"))
+	}
+
+	for _, origin := range cfg.CORSAllowOrigins {
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("CORS_ALLOW_ORIGINS: %w", err))
+		}
+	}
+	for _, origin := range cfg.AuthRedirectOrigins {
+		if err := validateOrigin(origin); err != nil {
+			errs = append(errs, fmt.Errorf("AUTH_REDIRECT_ORIGINS: %w", err))
+		}
+	}
+
+	for _, method := range cfg.CORSAllowMethods {
+		if method != strings.ToUpper(method) {
+			errs = append(errs, fmt.Errorf("CORS_ALLOW_METHODS: %s must be upper case", method))
+		}
+	}
+
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		errs = append(errs, errors.New("PORT must be between 1 and 65535"))
+	}
+	for _, proxy := range cfg.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			errs = append(errs, fmt.Errorf("TRUSTED_PROXIES: %s is not an IP or CIDR", proxy))
+		}
+	}
 
-func getEnv(key, fallback string) string {
-	if value, ok := os.LookupEnv(key); ok {
-		return value
+	timeouts := []struct {
+		key   string
+		value time.Duration
+	}{
+		{"READ_TIMEOUT", cfg.ReadTimeout},
+		{"WRITE_TIMEOUT", cfg.WriteTimeout},
+		{"IDLE_TIMEOUT", cfg.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", cfg.ShutdownTimeout},
+		{"READINESS_TIMEOUT", cfg.ReadinessTimeout},
+		{"IDEMPOTENCY_TTL", cfg.IdempotencyTTL},
 	}
-	return fallback
+	for _, t := range timeouts {
+		if t.value <= 0 {
+			errs = append(errs, fmt.Errorf("%s must be positive", t.key))
+		}
+	}
+	// requests still need time to finish once the drain delay is over
+	if cfg.ShutdownDrainDelay < 0 || cfg.ShutdownDrainDelay >= cfg.ShutdownTimeout {
+		errs = append(errs, errors.New("SHUTDOWN_DRAIN_DELAY must not be negative and must be shorter than SHUTDOWN_TIMEOUT"))
+	}
+	if cfg.ReadinessCacheTTL < 0 {
+		errs = append(errs, errors.New("READINESS_CACHE_TTL must not be negative"))
+	}
+	switch cfg.TraceExporter {
+	case "none", "stdout", "otlp":
+	default:
+		errs = append(errs, fmt.Errorf("TRACE_EXPORTER must be none, stdout or otlp, got %s", cfg.TraceExporter))
+	}
+	if cfg.TraceSampleRatio < 0 || cfg.TraceSampleRatio > 1 {
+		errs = append(errs, errors.New("TRACE_SAMPLE_RATIO must be between 0 and 1"))
+	}
+
+	if cfg.SecretRefreshInterval < 0 {
+		errs = append(errs, errors.New("SECRET_REFRESH_INTERVAL must not be negative"))
+	}
+
+	if cfg.LLMDailyBudget < 0 {
+		errs = append(errs, errors.New("LLM_DAILY_BUDGET must not be negative, use 0 to disable the budget"))
+	}
+
+	if cfg.MaxBodyBytes <= 0 {
+		errs = append(errs, errors.New("MAX_BODY_BYTES must be positive"))
+	}
+
+	return errs
 }
 
-func GetAdminEmails(cfg Config) []string {
-	emails := cfg.AdminEmails
-	if emails == "" {
+// validateOrigin checks that an origin is a bare scheme and host, as browsers
+// send it, or the * wildcard.
+func validateOrigin(origin string) error {
+	if origin == "*" {
 		return nil
 	}
-	return strings.Split(emails, ",")
+	parsed, err := url.Parse(origin)
+	if err != nil {
+		return fmt.Errorf("invalid origin %s: %w", origin, err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("origin %s must be an http or https URL", origin)
+	}
+	if parsed.Path != "" && parsed.Path != "/" {
+		return fmt.Errorf("origin %s must not include a path", origin)
+	}
+	return nil
+}
+
+// GetListenAddress returns the host:port the HTTP server binds to.
+func GetListenAddress(cfg Config) string {
+	return net.JoinHostPort(cfg.ListenHost, strconv.Itoa(cfg.Port))
 }
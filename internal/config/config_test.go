@@ -0,0 +1,147 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeConfigFile writes a config file holding every required setting plus
+// extra, so each test only spells out what it is about.
+func writeConfigFile(t *testing.T, extra string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	contents := `environment: TEST
+gcp_project_id: file-project
+firestore_database_id: file-database
+gemini_api_key: file-gemini-key
+google_client_id: file-client-id
+google_client_secret: file-client-secret
+` + extra
+	if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigEnvironmentOverridesFile(t *testing.T) {
+	path := writeConfigFile(t, "port: 9000\nadmin_emails: [a@example.com, b@example.com]\n")
+	t.Setenv("PORT", "9100")
+
+	cfg, err := LoadConfigWithResolver(path, SchemeResolver{})
+	if err != nil {
+		t.Fatalf("LoadConfigWithResolver: %v", err)
+	}
+	if cfg.Port != 9100 {
+		t.Errorf("Port = %d, want the environment value 9100", cfg.Port)
+	}
+	if cfg.GCPProjectID != "file-project" {
+		t.Errorf("GCPProjectID = %q, want the file value", cfg.GCPProjectID)
+	}
+	if got := strings.Join(cfg.AdminEmails, ","); got != "a@example.com,b@example.com" {
+		t.Errorf("AdminEmails = %q, want the file list", got)
+	}
+	if len(cfg.OIDCAudiences) != 1 || cfg.OIDCAudiences[0] != "file-client-id" {
+		t.Errorf("OIDCAudiences = %v, want the client id", cfg.OIDCAudiences)
+	}
+}
+
+func TestLoadConfigRejectsUnknownKeys(t *testing.T) {
+	path := writeConfigFile(t, "prot: 9000\n")
+
+	_, err := LoadConfigWithResolver(path, SchemeResolver{})
+	if err == nil || !strings.Contains(err.Error(), "unknown config file key prot") {
+		t.Fatalf("err = %v, want the unknown key reported", err)
+	}
+}
+
+func TestLoadConfigResolvesSecretRefs(t *testing.T) {
+	path := writeConfigFile(t, "metrics_token: memory://metrics/token\n")
+	t.Setenv("GEMINI_API_KEY", "memory://gemini/key")
+	secrets := NewMemoryResolver(map[string]string{
+		"memory://gemini/key":    "resolved-gemini-key",
+		"memory://metrics/token": "resolved-metrics-token",
+	})
+
+	cfg, err := LoadConfigWithResolver(path, SchemeResolver{"memory": secrets})
+	if err != nil {
+		t.Fatalf("LoadConfigWithResolver: %v", err)
+	}
+	if cfg.GeminiToken != "resolved-gemini-key" {
+		t.Errorf("GeminiToken = %q, want the resolved secret", cfg.GeminiToken)
+	}
+	if cfg.MetricsToken != "resolved-metrics-token" {
+		t.Errorf("MetricsToken = %q, want the resolved secret", cfg.MetricsToken)
+	}
+
+	// a rotated secret reaches the registered callback on the next refresh
+	refresher := cfg.NewSecretRefresher(0)
+	var rotated string
+	refresher.OnChange("GEMINI_API_KEY", func(value string) { rotated = value })
+	secrets.Set("memory://gemini/key", "rotated-gemini-key")
+	refresher.Refresh()
+	if rotated != "rotated-gemini-key" {
+		t.Errorf("rotated = %q, want the new secret", rotated)
+	}
+}
+
+func TestLoadConfigReportsMissingSecrets(t *testing.T) {
+	path := writeConfigFile(t, "")
+	t.Setenv("GEMINI_API_KEY", "memory://gemini/missing")
+
+	_, err := LoadConfigWithResolver(path, SchemeResolver{"memory": NewMemoryResolver(nil)})
+	if err == nil || !strings.Contains(err.Error(), "GEMINI_API_KEY: secret memory://gemini/missing not found") {
+		t.Fatalf("err = %v, want the missing secret reported", err)
+	}
+}
+
+func TestLoadConfigParsesLimitsAndDSN(t *testing.T) {
+	path := writeConfigFile(t, "rate_limit_default: 60/m:5\nrate_limit_routes: post /api/v2/parses=2/h\nsentry_dsn: https://public@sentry.example.com/42\n")
+
+	cfg, err := LoadConfigWithResolver(path, SchemeResolver{})
+	if err != nil {
+		t.Fatalf("LoadConfigWithResolver: %v", err)
+	}
+	if cfg.RateLimitDefault.Burst != 5 {
+		t.Errorf("RateLimitDefault.Burst = %d, want 5", cfg.RateLimitDefault.Burst)
+	}
+	if _, ok := cfg.RateLimitRoutes["POST /api/v2/parses"]; !ok || len(cfg.RateLimitRoutes) != 1 {
+		t.Errorf("RateLimitRoutes = %v, want only POST /api/v2/parses", cfg.RateLimitRoutes)
+	}
+	if cfg.SentryDSN == nil || cfg.SentryDSN.Endpoint != "https://sentry.example.com/api/42/store/" {
+		t.Errorf("SentryDSN = %+v, want the store endpoint of project 42", cfg.SentryDSN)
+	}
+}
+
+func TestLoadConfigReportsEveryInvalidValue(t *testing.T) {
+	path := writeConfigFile(t, "")
+	t.Setenv("RATE_LIMIT_DEFAULT", "10/d")
+	t.Setenv("RATE_LIMIT_ROUTES", "POST /api/v2/parses")
+	t.Setenv("SENTRY_DSN", "ftp://sentry.example.com/42")
+	t.Setenv("LLM_DAILY_BUDGET", "-1")
+
+	_, err := LoadConfigWithResolver(path, SchemeResolver{})
+	if err == nil {
+		t.Fatal("err = nil, want every invalid value reported")
+	}
+	for _, want := range []string{
+		"RATE_LIMIT_DEFAULT: invalid unit",
+		"RATE_LIMIT_ROUTES: invalid route limit",
+		"SENTRY_DSN: DSN must be an http or https URL",
+		"LLM_DAILY_BUDGET must not be negative",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("err = %v, want it to contain %q", err, want)
+		}
+	}
+}
+
+func TestLoadConfigRejectsDrainDelayPastShutdownTimeout(t *testing.T) {
+	path := writeConfigFile(t, "shutdown_timeout: 10s\nshutdown_drain_delay: 10s\n")
+
+	_, err := LoadConfigWithResolver(path, SchemeResolver{})
+	if err == nil || !strings.Contains(err.Error(), "SHUTDOWN_DRAIN_DELAY must not be negative and must be shorter than SHUTDOWN_TIMEOUT") {
+		t.Fatalf("err = %v, want the drain delay rejected", err)
+	}
+}
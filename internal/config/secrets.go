@@ -0,0 +1,248 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	secretmanager "google.golang.org/api/secretmanager/v1"
+)
+
+// secretResolveTimeout bounds a single lookup so a hung secret backend cannot
+// stall startup or a refresh forever.
+const secretResolveTimeout = 10 * time.Second
+
+// SecretResolver turns a reference such as file:///run/secrets/key into the
+// secret it points at.
+type SecretResolver interface {
+	Resolve(ctx context.Context, ref *url.URL) (string, error)
+}
+
+// SchemeResolver dispatches references to a resolver by URL scheme. Values
+// whose scheme is not registered are treated as plain values.
+type SchemeResolver map[string]SecretResolver
+
+// DefaultSecretResolver handles file:// and secretmanager:// references.
+func DefaultSecretResolver() SchemeResolver {
+	return SchemeResolver{
+		"file":          FileResolver{},
+		"secretmanager": &SecretManagerResolver{},
+	}
+}
+
+// parseRef returns the reference in value, or nil when value is a plain value.
+func (s SchemeResolver) parseRef(value string) *url.URL {
+	scheme, _, ok := strings.Cut(value, "://")
+	if !ok {
+		return nil
+	}
+	if _, known := s[scheme]; !known {
+		return nil
+	}
+	ref, err := url.Parse(value)
+	if err != nil {
+		return nil
+	}
+	return ref
+}
+
+func (s SchemeResolver) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	resolver, ok := s[ref.Scheme]
+	if !ok {
+		return "", fmt.Errorf("no resolver for %s references", ref.Scheme)
+	}
+	return resolver.Resolve(ctx, ref)
+}
+
+// FileResolver reads file:///path references, such as secrets mounted as
+// volumes. A trailing newline is dropped.
+type FileResolver struct{}
+
+func (FileResolver) Resolve(_ context.Context, ref *url.URL) (string, error) {
+	if ref.Host != "" && ref.Host != "localhost" {
+		return "", fmt.Errorf("file reference %s must use an absolute path, e.g. file:///run/secrets/key", ref.Redacted())
+	}
+	data, err := os.ReadFile(ref.Path)
+	if err != nil {
+		return "", fmt.Errorf("reading secret file: %w", err)
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// SecretManagerResolver reads secretmanager://project/secret/version
+// references from Google Secret Manager. The version defaults to latest. The
+// client is only created once a reference is seen.
+type SecretManagerResolver struct {
+	once    sync.Once
+	service *secretmanager.Service
+	err     error
+}
+
+func (r *SecretManagerResolver) Resolve(ctx context.Context, ref *url.URL) (string, error) {
+	project := ref.Host
+	parts := strings.Split(strings.Trim(ref.Path, "/"), "/")
+	if project == "" || len(parts) < 1 || len(parts) > 2 || parts[0] == "" {
+		return "", fmt.Errorf("secret manager reference %s must look like secretmanager://project/secret/version", ref.Redacted())
+	}
+	version := "latest"
+	if len(parts) == 2 && parts[1] != "" {
+		version = parts[1]
+	}
+
+	r.once.Do(func() {
+		// the client outlives this call, so it must not inherit a deadline
+		r.service, r.err = secretmanager.NewService(context.Background())
+	})
+	if r.err != nil {
+		return "", fmt.Errorf("creating secret manager client: %w", r.err)
+	}
+
+	name := fmt.Sprintf("projects/%s/secrets/%s/versions/%s", project, parts[0], version)
+	resp, err := r.service.Projects.Secrets.Versions.Access(name).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("accessing %s: %w", name, err)
+	}
+	data, err := base64.StdEncoding.DecodeString(resp.Payload.Data)
+	if err != nil {
+		return "", fmt.Errorf("decoding %s: %w", name, err)
+	}
+	return string(data), nil
+}
+
+// MemoryResolver serves secrets from a map keyed by the full reference, for
+// tests and local runs.
+type MemoryResolver struct {
+	mu      sync.RWMutex
+	secrets map[string]string
+}
+
+func NewMemoryResolver(secrets map[string]string) *MemoryResolver {
+	r := &MemoryResolver{secrets: map[string]string{}}
+	for ref, value := range secrets {
+		r.secrets[ref] = value
+	}
+	return r
+}
+
+// Set stores or rotates the secret behind ref.
+func (r *MemoryResolver) Set(ref, value string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.secrets[ref] = value
+}
+
+func (r *MemoryResolver) Resolve(_ context.Context, ref *url.URL) (string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	value, ok := r.secrets[ref.String()]
+	if !ok {
+		return "", fmt.Errorf("secret %s not found", ref.Redacted())
+	}
+	return value, nil
+}
+
+func resolveSecret(resolver SecretResolver, ref *url.URL) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), secretResolveTimeout)
+	defer cancel()
+	return resolver.Resolve(ctx, ref)
+}
+
+// SecretRefresher re-resolves the settings that were loaded from secret
+// references and hands changed values to the registered callbacks.
+type SecretRefresher struct {
+	resolver SecretResolver
+	refs     map[string]*url.URL
+	interval time.Duration
+
+	mu       sync.Mutex
+	values   map[string]string
+	handlers map[string][]func(value string)
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewSecretRefresher returns a refresher for every setting in cfg that came
+// from a secret reference. It does nothing until Start is called.
+func (cfg *Config) NewSecretRefresher(interval time.Duration) *SecretRefresher {
+	r := &SecretRefresher{
+		resolver: cfg.secretResolver,
+		refs:     map[string]*url.URL{},
+		interval: interval,
+		values:   map[string]string{},
+		handlers: map[string][]func(string){},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for key, secret := range cfg.secrets {
+		r.refs[key] = secret.ref
+		r.values[key] = secret.value
+	}
+	return r
+}
+
+// OnChange calls fn with the new value whenever the secret behind key rotates.
+func (r *SecretRefresher) OnChange(key string, fn func(value string)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[key] = append(r.handlers[key], fn)
+}
+
+// Start refreshes in the background until Stop is called. It is a no-op when
+// no settings came from references.
+func (r *SecretRefresher) Start() {
+	if len(r.refs) == 0 || r.interval <= 0 {
+		close(r.done)
+		return
+	}
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+				r.Refresh()
+			}
+		}
+	}()
+}
+
+// Stop ends background refreshes and waits for one in progress to finish.
+func (r *SecretRefresher) Stop() error {
+	close(r.stop)
+	<-r.done
+	return nil
+}
+
+// Refresh resolves every reference once. Failures keep the previous value.
+func (r *SecretRefresher) Refresh() {
+	for key, ref := range r.refs {
+		value, err := resolveSecret(r.resolver, ref)
+		if err != nil {
+			slog.Error("Error refreshing secret", "key", key, "error", err)
+			continue
+		}
+
+		r.mu.Lock()
+		changed := r.values[key] != value
+		r.values[key] = value
+		handlers := r.handlers[key]
+		r.mu.Unlock()
+
+		if changed {
+			slog.Info("Secret rotated", "key", key)
+			for _, handler := range handlers {
+				handler(value)
+			}
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/errorreport/dsn"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit/limit"
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// source looks settings up in the environment first and the config file
+// second, recording every value that fails to parse so they can all be
+// reported together. Values that are secret references are resolved.
+type source struct {
+	file     map[string]string
+	used     map[string]bool
+	errs     []error
+	resolver SchemeResolver
+	secrets  map[string]resolvedSecret
+}
+
+// resolvedSecret remembers where a setting came from so it can be refreshed.
+type resolvedSecret struct {
+	ref   *url.URL
+	value string
+}
+
+// newSource reads the config file at path, if any. Keys in the file are the
+// environment variable names in lower case, e.g. gcp_project_id.
+func newSource(path string, resolver SchemeResolver) (*source, error) {
+	s := &source{
+		file:     map[string]string{},
+		used:     map[string]bool{},
+		resolver: resolver,
+		secrets:  map[string]resolvedSecret{},
+	}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+
+	raw := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	var errs []error
+	for key, value := range raw {
+		flat, err := flatten(value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", key, err))
+			continue
+		}
+		s.file[strings.ToLower(key)] = flat
+	}
+	return s, errors.Join(errs...)
+}
+
+// flatten turns a file value into the string form the environment would hold,
+// joining lists with commas.
+func flatten(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case uint64:
+		return strconv.FormatUint(v, 10), nil
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), nil
+	case []any:
+		items := make([]string, 0, len(v))
+		for _, item := range v {
+			flat, err := flatten(item)
+			if err != nil {
+				return "", err
+			}
+			items = append(items, flat)
+		}
+		return strings.Join(items, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value of type %T", value)
+	}
+}
+
+func (s *source) lookup(key string) (string, bool) {
+	s.used[strings.ToLower(key)] = true
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		value, ok = s.file[strings.ToLower(key)]
+	}
+	if !ok {
+		return "", false
+	}
+
+	ref := s.resolver.parseRef(value)
+	if ref == nil {
+		return value, true
+	}
+	secret, err := resolveSecret(s.resolver, ref)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %w", key, err))
+		return "", true
+	}
+	s.secrets[key] = resolvedSecret{ref: ref, value: secret}
+	return secret, true
+}
+
+func (s *source) string(key, fallback string) string {
+	if value, ok := s.lookup(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func (s *source) int(key string, fallback int) int {
+	value, ok := s.lookup(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s must be an integer", key))
+		return fallback
+	}
+	return parsed
+}
+
+func (s *source) bool(key string, fallback bool) bool {
+	value, ok := s.lookup(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s must be true or false", key))
+		return fallback
+	}
+	return parsed
+}
+
+func (s *source) float(key string, fallback float64) float64 {
+	value, ok := s.lookup(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s must be a number", key))
+		return fallback
+	}
+	return parsed
+}
+
+func (s *source) duration(key string, fallback time.Duration) time.Duration {
+	value, ok := s.lookup(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s must be a duration such as 30s or 2m", key))
+		return fallback
+	}
+	return parsed
+}
+
+func (s *source) date(key string, fallback time.Time) time.Time {
+	value, ok := s.lookup(key)
+	if !ok {
+		return fallback
+	}
+	parsed, err := time.Parse(time.DateOnly, strings.TrimSpace(value))
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s must be a date such as 2027-04-30", key))
+		return fallback
+	}
+	return parsed
+}
+
+func (s *source) level(key string, fallback slog.Level) slog.Level {
+	value, ok := s.lookup(key)
+	if !ok {
+		return fallback
+	}
+	var parsed slog.Level
+	if err := parsed.UnmarshalText([]byte(strings.TrimSpace(value))); err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s must be one of debug, info, warn or error", key))
+		return fallback
+	}
+	return parsed
+}
+
+func (s *source) list(key string, fallback []string) []string {
+	if value, ok := s.lookup(key); ok {
+		return splitList(value)
+	}
+	return fallback
+}
+
+func (s *source) rateLimit(key, fallback string) limit.Limit {
+	value, ok := s.lookup(key)
+	if !ok {
+		value = fallback
+	}
+	parsed, err := limit.Parse(value)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %w", key, err))
+	}
+	return parsed
+}
+
+func (s *source) routeLimits(key, fallback string) map[string]limit.Limit {
+	value, ok := s.lookup(key)
+	if !ok {
+		value = fallback
+	}
+	parsed, err := limit.ParseRoutes(value)
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %w", key, err))
+	}
+	return parsed
+}
+
+// reportDSN returns nil when key is unset, leaving error reports local.
+func (s *source) reportDSN(key string) *dsn.DSN {
+	value, ok := s.lookup(key)
+	if !ok || strings.TrimSpace(value) == "" {
+		return nil
+	}
+	parsed, err := dsn.Parse(strings.TrimSpace(value))
+	if err != nil {
+		s.errs = append(s.errs, fmt.Errorf("%s: %w", key, err))
+	}
+	return parsed
+}
+
+// unknownKeys returns file keys that no setting read, which are usually typos.
+func (s *source) unknownKeys() []string {
+	var unknown []string
+	for key := range s.file {
+		if !s.used[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
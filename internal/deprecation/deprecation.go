@@ -0,0 +1,47 @@
+// Purpose:
+// Announces routes that are being retired with Deprecation (RFC 9745), Sunset (RFC 8594)
+// and successor Link headers, and logs who still calls them.
+
+package deprecation
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+)
+
+// Notice describes when a route was deprecated and when it may be removed.
+type Notice struct {
+	Since  time.Time
+	Sunset time.Time
+}
+
+// Middleware marks responses from the route as deprecated. successor is the
+// path of the replacement route, where :params are filled in from the request,
+// e.g. /api/v2/scorecards/:documentId.
+func (n Notice) Middleware(successor string) gin.HandlerFunc {
+	deprecation := fmt.Sprintf("@%d", n.Since.Unix())
+	sunset := n.Sunset.UTC().Format(http.TimeFormat)
+	return func(c *gin.Context) {
+		link := successor
+		for _, param := range c.Params {
+			link = strings.ReplaceAll(link, ":"+param.Key, param.Value)
+		}
+		c.Header("Deprecation", deprecation)
+		c.Header("Sunset", sunset)
+		c.Header("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, link))
+
+		// knowing who still calls a route tells us when it is safe to remove
+		email := ""
+		if user, ok := auth.UserFromContext(c); ok {
+			email = user.Email
+		}
+		logging.FromContext(c.Request.Context()).Info("Deprecated route called", "route", c.FullPath(), "user", email, "successor", successor)
+		c.Next()
+	}
+}
@@ -0,0 +1,49 @@
+// Purpose:
+// Parses Sentry DSNs into the endpoint and keys error reports are sent with.
+// Kept free of the sinks so config can check a DSN while loading.
+
+package dsn
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DSN is a parsed Sentry DSN, https://<key>@<host>/<project>.
+type DSN struct {
+	Endpoint  string
+	PublicKey string
+	SecretKey string
+}
+
+// Parse reads a DSN and works out the store endpoint events are posted to.
+func Parse(dsn string) (*DSN, error) {
+	parsed, err := url.Parse(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DSN: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return nil, errors.New("DSN must be an http or https URL")
+	}
+	if parsed.User == nil || parsed.User.Username() == "" {
+		return nil, errors.New("DSN is missing the public key")
+	}
+	path := strings.Trim(parsed.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	project := path[slash+1:]
+	if project == "" {
+		return nil, errors.New("DSN is missing the project id")
+	}
+	prefix := ""
+	if slash >= 0 {
+		prefix = "/" + path[:slash]
+	}
+	secret, _ := parsed.User.Password()
+	return &DSN{
+		Endpoint:  fmt.Sprintf("%s://%s%s/api/%s/store/", parsed.Scheme, parsed.Host, prefix, project),
+		PublicKey: parsed.User.Username(),
+		SecretKey: secret,
+	}, nil
+}
@@ -0,0 +1,60 @@
+// Purpose:
+// Reports unexpected failures, such as recovered panics, to pluggable sinks.
+// Sinks log locally or forward to a Sentry-compatible endpoint.
+
+package errorreport
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+)
+
+// Event describes one failure and the request it happened in.
+type Event struct {
+	ID        string
+	Time      time.Time
+	Message   string
+	Stack     string
+	RequestID string
+	Method    string
+	Route     string
+	URL       string
+	User      string
+}
+
+// Sink receives events. Report must not block on slow backends.
+type Sink interface {
+	Report(ctx context.Context, event Event) error
+}
+
+// Sinks reports to every sink in turn.
+type Sinks []Sink
+
+func (s Sinks) Report(ctx context.Context, event Event) error {
+	var errs []error
+	for _, sink := range s {
+		if err := sink.Report(ctx, event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// LogSink writes events to the request logger, which carries the request ID
+// and trace, so panics show up in Cloud Error Reporting next to their logs.
+type LogSink struct{}
+
+func (LogSink) Report(ctx context.Context, event Event) error {
+	logging.FromContext(ctx).LogAttrs(ctx, slog.LevelError, "Recovered from panic",
+		slog.String("event_id", event.ID),
+		slog.String("panic", event.Message),
+		slog.String("route", event.Route),
+		slog.String("user", event.User),
+		slog.String("stack_trace", event.Stack),
+	)
+	return nil
+}
@@ -0,0 +1,56 @@
+package errorreport
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+)
+
+// Recovery turns a panic anywhere later in the chain into a problem+json 500
+// carrying the request ID, and reports it to sink with the request's route
+// and user.
+func Recovery(sink Sink) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// the client went away or the handler asked for the connection
+			// to be dropped, there is nobody to answer
+			if recovered == http.ErrAbortHandler {
+				panic(recovered)
+			}
+
+			event := Event{
+				ID:        uuid.New().String(),
+				Time:      time.Now(),
+				Message:   fmt.Sprint(recovered),
+				Stack:     string(debug.Stack()),
+				RequestID: logging.RequestID(c),
+				Method:    c.Request.Method,
+				Route:     c.FullPath(),
+				URL:       c.Request.URL.String(),
+			}
+			if user, ok := auth.UserFromContext(c); ok {
+				event.User = user.Email
+			}
+			if err := sink.Report(c.Request.Context(), event); err != nil {
+				logging.FromContext(c.Request.Context()).Error("Error reporting panic", "event_id", event.ID, "error", err)
+			}
+
+			c.Abort()
+			if !c.Writer.Written() {
+				apierror.Write(c, apierror.Internal("internal server error", nil))
+			}
+		}()
+		c.Next()
+	}
+}
@@ -0,0 +1,147 @@
+package errorreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/errorreport/dsn"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/tracing"
+)
+
+const (
+	// sentryQueueSize bounds the events waiting to be sent. Events beyond it
+	// are dropped rather than stalling requests behind a slow endpoint.
+	sentryQueueSize = 64
+	sentryTimeout   = 10 * time.Second
+)
+
+var errQueueFull = errors.New("error report queue is full")
+
+// HTTPSink posts events to a Sentry-compatible store endpoint. Events are
+// queued and sent in the background, so Report never waits on the network.
+type HTTPSink struct {
+	dsn         *dsn.DSN
+	environment string
+	serverName  string
+	client      *http.Client
+
+	mu     sync.RWMutex
+	closed bool
+	queue  chan Event
+	done   chan struct{}
+}
+
+func NewHTTPSink(target *dsn.DSN, environment string) *HTTPSink {
+	serverName, _ := os.Hostname()
+	s := &HTTPSink{
+		dsn:         target,
+		environment: environment,
+		serverName:  serverName,
+		client:      &http.Client{Timeout: sentryTimeout},
+		queue:       make(chan Event, sentryQueueSize),
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *HTTPSink) Report(_ context.Context, event Event) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return errors.New("error report sink is closed")
+	}
+	select {
+	case s.queue <- event:
+		return nil
+	default:
+		return errQueueFull
+	}
+}
+
+// Close stops accepting events and waits for queued ones to be sent.
+func (s *HTTPSink) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return nil
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.done)
+	for event := range s.queue {
+		if err := s.send(event); err != nil {
+			slog.Error("Error sending error report", "event_id", event.ID, "error", err)
+		}
+	}
+}
+
+func (s *HTTPSink) send(event Event) (err error) {
+	ctx, cancel := context.WithTimeout(context.Background(), sentryTimeout)
+	defer cancel()
+	ctx, span := tracing.Start(ctx, "errorreport.Send")
+	defer tracing.End(span, &err)
+
+	body, err := json.Marshal(s.payload(event))
+	if err != nil {
+		return fmt.Errorf("encoding event: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.dsn.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	auth := fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s/1.0, sentry_key=%s", tracing.ServiceName, s.dsn.PublicKey)
+	if s.dsn.SecretKey != "" {
+		auth += ", sentry_secret=" + s.dsn.SecretKey
+	}
+	req.Header.Set("X-Sentry-Auth", auth)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("error report endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// payload builds a store API event. The raw Go stack is sent as extra data
+// since it does not map cleanly onto Sentry frames.
+func (s *HTTPSink) payload(event Event) map[string]any {
+	payload := map[string]any{
+		"event_id":    strings.ReplaceAll(event.ID, "-", ""),
+		"timestamp":   event.Time.UTC().Format(time.RFC3339Nano),
+		"level":       "fatal",
+		"platform":    "go",
+		"logger":      "panic",
+		"server_name": s.serverName,
+		"environment": s.environment,
+		"transaction": event.Method + " " + event.Route,
+		"exception": map[string]any{
+			"values": []map[string]any{{"type": "panic", "value": event.Message}},
+		},
+		"request": map[string]any{"method": event.Method, "url": event.URL},
+		"tags":    map[string]string{"request_id": event.RequestID, "route": event.Route},
+		"extra":   map[string]string{"stack_trace": event.Stack},
+	}
+	if event.User != "" {
+		payload["user"] = map[string]string{"email": event.User}
+	}
+	return payload
+}
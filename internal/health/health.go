@@ -0,0 +1,152 @@
+// Purpose:
+// Reports whether the server is alive and whether its dependencies are reachable.
+// Dependency checks are cached so frequent probes do not hammer upstream services.
+
+package health
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+)
+
+const (
+	StatusOK          = "ok"
+	StatusUnavailable = "unavailable"
+	StatusDraining    = "draining"
+)
+
+// Check probes a single dependency, returning an error when it is unusable.
+type Check func(ctx context.Context) error
+
+// ComponentStatus is the outcome of one check. Why a check failed is only
+// logged, since the endpoint is public and errors can name internal hosts.
+type ComponentStatus struct {
+	Status    string    `json:"status"`
+	LatencyMs int64     `json:"latency_ms"`
+	CheckedAt time.Time `json:"checked_at"`
+}
+
+// Report is the body returned by the readiness endpoint.
+type Report struct {
+	Status     string                     `json:"status"`
+	Components map[string]ComponentStatus `json:"components"`
+}
+
+type component struct {
+	name  string
+	check Check
+}
+
+// Checker runs the registered checks, reusing results for ttl.
+type Checker struct {
+	ttl        time.Duration
+	timeout    time.Duration
+	draining   func() bool
+	components []component
+
+	mu       sync.Mutex
+	cached   map[string]ComponentStatus
+	cachedAt time.Time
+}
+
+// NewChecker returns a checker whose results are cached for ttl, with each
+// check given at most timeout. draining reports whether shutdown has started.
+func NewChecker(ttl, timeout time.Duration, draining func() bool) *Checker {
+	return &Checker{ttl: ttl, timeout: timeout, draining: draining}
+}
+
+// Register adds a dependency check under name.
+func (h *Checker) Register(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.components = append(h.components, component{name: name, check: check})
+	h.cached = nil
+}
+
+// Run returns the status of every component, running the checks again only
+// when the cached results are older than the ttl.
+func (h *Checker) Run(ctx context.Context) Report {
+	// holding the lock while checks run means concurrent probes share one run
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.cached == nil || time.Since(h.cachedAt) > h.ttl {
+		h.cached = h.runAll(ctx)
+		h.cachedAt = time.Now()
+	}
+
+	report := Report{Status: StatusOK, Components: h.cached}
+	for _, status := range h.cached {
+		if status.Status != StatusOK {
+			report.Status = StatusUnavailable
+		}
+	}
+	if h.draining != nil && h.draining() {
+		report.Status = StatusDraining
+	}
+	return report
+}
+
+func (h *Checker) runAll(ctx context.Context) map[string]ComponentStatus {
+	results := make(map[string]ComponentStatus, len(h.components))
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range h.components {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			status := h.runOne(ctx, c)
+			mu.Lock()
+			results[c.name] = status
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	return results
+}
+
+func (h *Checker) runOne(ctx context.Context, c component) ComponentStatus {
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	start := time.Now()
+	err := c.check(ctx)
+	status := ComponentStatus{
+		Status:    StatusOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+		CheckedAt: start,
+	}
+	if err != nil {
+		status.Status = StatusUnavailable
+		logging.FromContext(ctx).Warn("Readiness check failed", "component", c.name, "error", err)
+	}
+	return status
+}
+
+// HandleLive reports that the process is up. It does not look at
+// dependencies, so a flaky upstream never gets the instance restarted.
+func HandleLive() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": StatusOK})
+	}
+}
+
+// HandleReady reports per-component status, returning 503 when any
+// dependency is down or the server is draining.
+func HandleReady(h *Checker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// checks use a fresh context so a probe that hangs up does not
+		// cache a cancelled result for everyone else
+		report := h.Run(context.WithoutCancel(c.Request.Context()))
+		code := http.StatusOK
+		if report.Status != StatusOK {
+			code = http.StatusServiceUnavailable
+		}
+		c.JSON(code, report)
+	}
+}
@@ -0,0 +1,347 @@
+// Purpose:
+// Makes retried writes safe by replaying the first response for an Idempotency-Key.
+// Responses are kept in Firestore until a TTL policy on expires_at removes them.
+
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// KeyHeader is sent by clients that want a request to be applied at most once.
+	KeyHeader = "Idempotency-Key"
+	// ReplayedHeader marks a response that was replayed rather than produced.
+	ReplayedHeader = "Idempotent-Replayed"
+
+	collection = "idempotency-keys"
+	maxKeyLen  = 255
+	// firestore documents are capped at 1MiB, larger responses are not kept
+	maxStoredBody = 900 << 10
+	// leaseMargin is added to the request timeout so a slow request keeps its
+	// key until the server would have given up on it anyway
+	leaseMargin = 30 * time.Second
+)
+
+// storedHeaders are the response headers kept alongside the body, since a
+// replay without them would tell the client less than the first response.
+// Headers set by middleware before this one are set again on replay.
+var storedHeaders = []string{"Location"}
+
+const (
+	stateInProgress = "in_progress"
+	stateCompleted  = "completed"
+)
+
+// KeyDocument is a key as stored in Firestore. A key belongs to one user and
+// one path, so two users or two endpoints never share a response.
+type KeyDocument struct {
+	Key         string            `firestore:"key"`
+	Email       string            `firestore:"email"`
+	Method      string            `firestore:"method"`
+	Path        string            `firestore:"path"`
+	BodyHash    string            `firestore:"body_hash"`
+	State       string            `firestore:"state"`
+	Status      int               `firestore:"status"`
+	ContentType string            `firestore:"content_type"`
+	Headers     map[string]string `firestore:"headers"`
+	Body        []byte            `firestore:"body"`
+	CreatedAt   time.Time         `firestore:"created_at"`
+	ExpiresAt   time.Time         `firestore:"expires_at"`
+	// LockedUntil is when an in progress key is given up on, as an instance
+	// that crashed mid request never releases it
+	LockedUntil time.Time `firestore:"locked_until"`
+	// Claim identifies the request holding the key, so one that outlived its
+	// lease cannot overwrite or release the key of the request that took over
+	Claim string `firestore:"claim"`
+	// BodyOmitted marks a completed response too large to keep, which is
+	// remembered so a retry is refused rather than applied a second time
+	BodyOmitted bool `firestore:"body_omitted"`
+}
+
+// errClaimLost is returned when a key was taken over while its request ran.
+var errClaimLost = errors.New("idempotency key was taken over by another request")
+
+// Store keeps responses for ttl. Firestore deletes expired keys lazily, so
+// they are also ignored once past expires_at.
+type Store struct {
+	client *firestore.Client
+	ttl    time.Duration
+	lease  time.Duration
+}
+
+// NewStore returns a store whose in progress keys are held for at most
+// requestTimeout, plus a margin, before another request may take them over.
+func NewStore(client *firestore.Client, ttl, requestTimeout time.Duration) *Store {
+	return &Store{client: client, ttl: ttl, lease: requestTimeout + leaseMargin}
+}
+
+// Middleware replays the stored response when a request repeats an
+// Idempotency-Key with the same body, and rejects the key with 422 when the
+// body differs. Requests without the header are untouched. Mount it after the
+// auth middleware and before anything that spends quota.
+func (s *Store) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(KeyHeader)
+		if key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxKeyLen {
+			apierror.Abort(c, apierror.Validation("invalid_idempotency_key", fmt.Sprintf("%s must be at most %d characters", KeyHeader, maxKeyLen)))
+			return
+		}
+		user, ok := auth.RequireUser(c)
+		if !ok {
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apierror.Abort(c, apierror.PayloadTooLarge("", fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit)))
+				return
+			}
+			apierror.Abort(c, apierror.Validation("invalid_body", "failed to read request body"))
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		ctx := c.Request.Context()
+		hash := bodyHash(c.GetHeader("Content-Type"), body)
+		reference := s.client.Collection(collection).Doc(documentID(user.Email, c.Request.Method, c.Request.URL.Path, key))
+		claim := rand.Text()
+		existing, err := s.claim(ctx, reference, KeyDocument{
+			Key:      key,
+			Email:    user.Email,
+			Method:   c.Request.Method,
+			Path:     c.Request.URL.Path,
+			BodyHash: hash,
+			Claim:    claim,
+		})
+		if err != nil {
+			apierror.Abort(c, apierror.Internal("failed to check idempotency key", err))
+			return
+		}
+		if existing != nil {
+			replay(c, existing, hash)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: c.Writer}
+		c.Writer = recorder
+		completed := false
+		defer func() {
+			// a panic or a failure that is worth retrying frees the key
+			if !completed {
+				err := s.whileClaimed(context.WithoutCancel(ctx), reference, claim, func(tx *firestore.Transaction) error {
+					return tx.Delete(reference)
+				})
+				if err != nil && !errors.Is(err, errClaimLost) {
+					logging.FromContext(ctx).Error("Error releasing idempotency key", "error", err)
+				}
+			}
+		}()
+
+		c.Next()
+		// errors are normally written on the way out, but the response has to
+		// exist before it can be stored
+		apierror.Render(c)
+
+		statusCode := c.Writer.Status()
+		if statusCode >= http.StatusInternalServerError || statusCode == http.StatusTooManyRequests {
+			return
+		}
+		// a response too large to keep still marks the key completed, since
+		// freeing it would let a retry apply the write again
+		body, omitted := recorder.body.Bytes(), recorder.body.Len() > maxStoredBody
+		if omitted {
+			body = nil
+		}
+		err = s.whileClaimed(context.WithoutCancel(ctx), reference, claim, func(tx *firestore.Transaction) error {
+			return tx.Update(reference, []firestore.Update{
+				{Path: "state", Value: stateCompleted},
+				{Path: "status", Value: statusCode},
+				{Path: "content_type", Value: c.Writer.Header().Get("Content-Type")},
+				{Path: "headers", Value: responseHeaders(c.Writer.Header())},
+				{Path: "body", Value: body},
+				{Path: "body_omitted", Value: omitted},
+			})
+		})
+		if errors.Is(err, errClaimLost) {
+			completed = true
+			logging.FromContext(ctx).Warn("Idempotency key was taken over before the response was stored")
+			return
+		}
+		if err != nil {
+			logging.FromContext(ctx).Error("Error storing idempotent response", "error", err)
+			return
+		}
+		completed = true
+	}
+}
+
+// claim records the key as in progress, or returns the document already
+// stored for it. Keys left in progress past their lease are taken over.
+func (s *Store) claim(ctx context.Context, reference *firestore.DocumentRef, doc KeyDocument) (*KeyDocument, error) {
+	var existing *KeyDocument
+	err := s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		existing = nil
+		now := time.Now().UTC()
+		snapshot, err := tx.Get(reference)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			var stored KeyDocument
+			if err := snapshot.DataTo(&stored); err != nil {
+				return err
+			}
+			if !claimable(&stored, now) {
+				existing = &stored
+				return nil
+			}
+		}
+
+		doc.State = stateInProgress
+		doc.CreatedAt = now
+		doc.ExpiresAt = now.Add(s.ttl)
+		doc.LockedUntil = now.Add(s.lease)
+		return tx.Set(reference, doc)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// claimable reports whether a stored key may be claimed afresh at now, which
+// it may once expired or once an in progress request has outlived its lease.
+func claimable(stored *KeyDocument, now time.Time) bool {
+	abandoned := stored.State == stateInProgress && now.After(stored.LockedUntil)
+	return !now.Before(stored.ExpiresAt) || abandoned
+}
+
+// whileClaimed runs write only while the key is still held by claim.
+func (s *Store) whileClaimed(ctx context.Context, reference *firestore.DocumentRef, claim string, write func(*firestore.Transaction) error) error {
+	return s.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		snapshot, err := tx.Get(reference)
+		if status.Code(err) == codes.NotFound {
+			return errClaimLost
+		}
+		if err != nil {
+			return err
+		}
+		var stored KeyDocument
+		if err := snapshot.DataTo(&stored); err != nil {
+			return err
+		}
+		if stored.Claim != claim {
+			return errClaimLost
+		}
+		return write(tx)
+	})
+}
+
+// replay answers a repeated key from what is stored for it.
+func replay(c *gin.Context, stored *KeyDocument, hash string) {
+	switch {
+	case stored.BodyHash != hash:
+		apierror.Abort(c, apierror.Unprocessable("idempotency_key_reused", "this Idempotency-Key was already used with a different request body"))
+	case stored.State == stateCompleted:
+		for name, value := range stored.Headers {
+			c.Header(name, value)
+		}
+		c.Header(ReplayedHeader, "true")
+		if stored.BodyOmitted {
+			apierror.Abort(c, apierror.Conflict("idempotency_key_completed", "a request with this Idempotency-Key already succeeded but its response was too large to keep"))
+			return
+		}
+		c.Data(stored.Status, stored.ContentType, stored.Body)
+		c.Abort()
+	default:
+		apierror.Abort(c, apierror.Conflict("idempotency_key_in_progress", "a request with this Idempotency-Key is still being processed").WithRetryAfter(time.Second))
+	}
+}
+
+// responseHeaders picks the stored headers out of a response.
+func responseHeaders(header http.Header) map[string]string {
+	headers := make(map[string]string)
+	for _, name := range storedHeaders {
+		if value := header.Get(name); value != "" {
+			headers[name] = value
+		}
+	}
+	return headers
+}
+
+// documentID keeps keys from different users and endpoints apart without
+// putting raw emails or keys in document names.
+func documentID(email, method, path, key string) string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{email, method, path, key}, "\x00")))
+	return hex.EncodeToString(sum[:])
+}
+
+// bodyHash hashes what the request says rather than how it was encoded.
+// Multipart bodies are hashed part by part, since clients pick a new random
+// boundary when they rebuild a request to retry it.
+func bodyHash(contentType string, body []byte) string {
+	hash := sha256.New()
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err == nil && mediaType == "multipart/form-data" && params["boundary"] != "" {
+		reader := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				return hex.EncodeToString(hash.Sum(nil))
+			}
+			if err != nil {
+				break
+			}
+			fmt.Fprintf(hash, "%s\x00%s\x00", part.FormName(), part.FileName())
+			io.Copy(hash, part)
+			hash.Write([]byte{0})
+		}
+		// malformed multipart falls back to the raw bytes
+		hash.Reset()
+	}
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder keeps a copy of the response body as it is written.
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *responseRecorder) WriteString(s string) (int, error) {
+	r.body.WriteString(s)
+	return r.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,137 @@
+package idempotency
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+)
+
+// multipartBody encodes a game field and an image file with boundary.
+func multipartBody(t *testing.T, boundary, game string) (string, []byte) {
+	t.Helper()
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.SetBoundary(boundary); err != nil {
+		t.Fatal(err)
+	}
+	if err := writer.WriteField("game", game); err != nil {
+		t.Fatal(err)
+	}
+	file, err := writer.CreateFormFile("image", "scorecard.jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Write([]byte("jpeg bytes"))
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return writer.FormDataContentType(), body.Bytes()
+}
+
+func TestBodyHash(t *testing.T) {
+	contentType, body := multipartBody(t, "first-boundary", "wingspan")
+	retriedType, retried := multipartBody(t, "second-boundary", "wingspan")
+	changedType, changed := multipartBody(t, "second-boundary", "azul")
+	malformed := []byte("--first-boundary\r\nnot a part header\r\n")
+	truncated := body[:len(body)-20]
+
+	tests := []struct {
+		name  string
+		a, b  string
+		equal bool
+	}{
+		{"multipart with a new boundary", bodyHash(contentType, body), bodyHash(retriedType, retried), true},
+		{"multipart with a changed field", bodyHash(contentType, body), bodyHash(changedType, changed), false},
+		{"malformed multipart falls back to the raw bytes", bodyHash(contentType, malformed), bodyHash("", malformed), true},
+		{"truncated multipart falls back to the raw bytes", bodyHash(contentType, truncated), bodyHash("", truncated), true},
+		{"json is hashed as sent", bodyHash("application/json", []byte(`{"a":1}`)), bodyHash("application/json", []byte(`{"a": 1}`)), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if (tt.a == tt.b) != tt.equal {
+				t.Errorf("hashes %s and %s, want equal %t", tt.a, tt.b, tt.equal)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	completed := &KeyDocument{
+		BodyHash:    "hash",
+		State:       stateCompleted,
+		Status:      http.StatusCreated,
+		ContentType: "application/json",
+		Headers:     map[string]string{"Location": "/api/v1/scorecards/s1"},
+		Body:        []byte(`{"id":"s1"}`),
+	}
+	omitted := *completed
+	omitted.Body, omitted.BodyOmitted = nil, true
+
+	tests := []struct {
+		name       string
+		stored     *KeyDocument
+		hash       string
+		status     int
+		body       string
+		location   string
+		retryAfter string
+	}{
+		{"completed", completed, "hash", http.StatusCreated, `{"id":"s1"}`, "/api/v1/scorecards/s1", ""},
+		{"changed body", completed, "other", http.StatusUnprocessableEntity, "", "", ""},
+		{"in progress", &KeyDocument{BodyHash: "hash", State: stateInProgress}, "hash", http.StatusConflict, "", "", "1"},
+		{"completed without a stored body", &omitted, "hash", http.StatusConflict, "", "/api/v1/scorecards/s1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.Use(apierror.Middleware())
+			r.POST("/", func(c *gin.Context) { replay(c, tt.stored, tt.hash) })
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+
+			if w.Code != tt.status {
+				t.Errorf("status = %d, want %d, body %s", w.Code, tt.status, w.Body)
+			}
+			if tt.body != "" && w.Body.String() != tt.body {
+				t.Errorf("body = %s, want %s", w.Body, tt.body)
+			}
+			if got := w.Header().Get("Location"); got != tt.location {
+				t.Errorf("Location = %q, want %q", got, tt.location)
+			}
+			if got := w.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func TestClaimable(t *testing.T) {
+	now := time.Now()
+	live, expired := now.Add(time.Hour), now.Add(-time.Second)
+
+	tests := []struct {
+		name   string
+		stored KeyDocument
+		want   bool
+	}{
+		{"completed", KeyDocument{State: stateCompleted, ExpiresAt: live}, false},
+		{"completed past its ttl", KeyDocument{State: stateCompleted, ExpiresAt: expired}, true},
+		{"in progress within its lease", KeyDocument{State: stateInProgress, ExpiresAt: live, LockedUntil: live}, false},
+		{"in progress past its lease", KeyDocument{State: stateInProgress, ExpiresAt: live, LockedUntil: expired}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := claimable(&tt.stored, now); got != tt.want {
+				t.Errorf("claimable = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
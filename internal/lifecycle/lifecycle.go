@@ -0,0 +1,133 @@
+// Purpose:
+// Tracks the pieces of a running server that must be wound down on shutdown.
+// Drains in-flight requests and background jobs, then closes clients.
+
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type closer struct {
+	name  string
+	close func() error
+}
+
+// Manager owns background jobs and the clients created at startup.
+type Manager struct {
+	draining atomic.Bool
+	// drainDelay is how long requests are still served once draining, so
+	// load balancers see the failing health checks before the listener closes
+	drainDelay time.Duration
+
+	// jobsCtx is cancelled once the shutdown deadline passes, so jobs still
+	// running at that point can give up
+	jobsCtx    context.Context
+	cancelJobs context.CancelFunc
+	jobs       sync.WaitGroup
+	// jobsMu orders Go's jobs.Add before Shutdown's jobs.Wait, which a
+	// WaitGroup requires. jobsClosed is set once in-flight requests are
+	// drained, after which no new background jobs are accepted.
+	jobsMu     sync.Mutex
+	jobsClosed bool
+
+	mu      sync.Mutex
+	closers []closer
+}
+
+// NewManager returns a manager that keeps serving for drainDelay after
+// shutdown starts.
+func NewManager(drainDelay time.Duration) *Manager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Manager{drainDelay: drainDelay, jobsCtx: ctx, cancelJobs: cancel}
+}
+
+// Draining reports whether shutdown has started.
+func (m *Manager) Draining() bool {
+	return m.draining.Load()
+}
+
+// Go runs fn in the background and waits for it during shutdown. Requests
+// still draining may start jobs, but once they finish new jobs are refused.
+func (m *Manager) Go(name string, fn func(ctx context.Context)) bool {
+	m.jobsMu.Lock()
+	if m.jobsClosed {
+		m.jobsMu.Unlock()
+		slog.Warn("Skipping background job, server is shutting down", "job", name)
+		return false
+	}
+	m.jobs.Add(1)
+	m.jobsMu.Unlock()
+
+	go func() {
+		defer m.jobs.Done()
+		fn(m.jobsCtx)
+	}()
+	return true
+}
+
+// OnClose registers a client to close on shutdown. Clients are closed in the
+// reverse of the order they were registered.
+func (m *Manager) OnClose(name string, close func() error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.closers = append(m.closers, closer{name: name, close: close})
+}
+
+// Shutdown reports draining for the drain delay, then stops srv accepting
+// requests, waits for in-flight requests and background jobs until ctx is
+// done, and closes every registered client.
+func (m *Manager) Shutdown(ctx context.Context, srv *http.Server) error {
+	m.draining.Store(true)
+
+	// health checks only fail while the listener is still open to answer them
+	if m.drainDelay > 0 {
+		timer := time.NewTimer(m.drainDelay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+		}
+	}
+
+	var errs []error
+	if err := srv.Shutdown(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("draining requests: %w", err))
+	}
+	m.jobsMu.Lock()
+	m.jobsClosed = true
+	m.jobsMu.Unlock()
+
+	jobsDone := make(chan struct{})
+	go func() {
+		m.jobs.Wait()
+		close(jobsDone)
+	}()
+	select {
+	case <-jobsDone:
+	case <-ctx.Done():
+		// give up on stragglers but still close clients below
+		m.cancelJobs()
+		errs = append(errs, fmt.Errorf("draining background jobs: %w", ctx.Err()))
+	}
+	m.cancelJobs()
+
+	m.mu.Lock()
+	closers := m.closers
+	m.closers = nil
+	m.mu.Unlock()
+	for i := len(closers) - 1; i >= 0; i-- {
+		if err := closers[i].close(); err != nil {
+			errs = append(errs, fmt.Errorf("closing %s: %w", closers[i].name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,80 @@
+package lifecycle
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/health"
+)
+
+func TestShutdownReportsDrainingBeforeClosing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	m := NewManager(300 * time.Millisecond)
+	r := gin.New()
+	r.GET("/readyz", health.HandleReady(health.NewChecker(time.Minute, time.Second, m.Draining)))
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	readyz := func() int {
+		t.Helper()
+		resp, err := http.Get(srv.URL + "/readyz")
+		if err != nil {
+			t.Fatalf("GET /readyz: %v", err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if code := readyz(); code != http.StatusOK {
+		t.Fatalf("/readyz before shutdown = %d, want 200", code)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- m.Shutdown(ctx, srv.Config) }()
+	for !m.Draining() {
+		time.Sleep(time.Millisecond)
+	}
+
+	// the listener stays open through the drain delay
+	if code := readyz(); code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz while draining = %d, want 503", code)
+	}
+	// requests still in flight may start jobs, which shutdown waits for
+	jobDone := false
+	if !m.Go("during drain", func(context.Context) {
+		time.Sleep(50 * time.Millisecond)
+		jobDone = true
+	}) {
+		t.Error("Go during the drain delay was refused")
+	}
+
+	if err := <-shutdown; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if !jobDone {
+		t.Error("Shutdown returned before the job started during the drain delay finished")
+	}
+	if m.Go("after shutdown", func(context.Context) { t.Error("job ran after shutdown") }) {
+		t.Error("Go after shutdown was accepted")
+	}
+}
+
+func TestShutdownDrainDelayIsBoundedByDeadline(t *testing.T) {
+	m := NewManager(time.Hour)
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	m.Shutdown(ctx, srv.Config)
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Shutdown took %s, want it to stop waiting at the deadline", elapsed)
+	}
+}
@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// RequestIDHeader is read from incoming requests and echoed on responses.
+const RequestIDHeader = "X-Request-ID"
+
+const requestIDContextKey = "logging.request_id"
+
+// validRequestID keeps caller supplied IDs short and safe to log.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Middleware gives each request an ID and a logger carrying that ID and the
+// Cloud trace, then writes one access log line with an httpRequest payload
+// once the request completes.
+func Middleware(projectID string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(RequestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = uuid.New().String()
+		}
+		c.Set(requestIDContextKey, requestID)
+		c.Header(RequestIDHeader, requestID)
+
+		attrs := []any{slog.String("request_id", requestID)}
+		attrs = append(attrs, traceAttrs(projectID, trace.SpanContextFromContext(c.Request.Context()), c.GetHeader("X-Cloud-Trace-Context"))...)
+		ctx := WithLogger(c.Request.Context(), FromContext(c.Request.Context()).With(attrs...))
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= 500:
+			level = slog.LevelError
+		case status >= 400:
+			level = slog.LevelWarn
+		}
+
+		// the handler chain may have enriched the logger, e.g. with the user
+		logger := FromContext(c.Request.Context())
+		logger.LogAttrs(c.Request.Context(), level, fmt.Sprintf("%s %s %d", c.Request.Method, c.Request.URL.Path, status),
+			slog.String("route", c.FullPath()),
+			slog.Group("httpRequest",
+				slog.String("requestMethod", c.Request.Method),
+				slog.String("requestUrl", c.Request.URL.String()),
+				slog.String("requestSize", strconv.FormatInt(c.Request.ContentLength, 10)),
+				slog.Int("status", status),
+				slog.String("responseSize", strconv.Itoa(c.Writer.Size())),
+				slog.String("userAgent", c.Request.UserAgent()),
+				slog.String("remoteIp", c.ClientIP()),
+				slog.String("referer", c.Request.Referer()),
+				slog.String("protocol", c.Request.Proto),
+				slog.String("latency", fmt.Sprintf("%.6fs", time.Since(start).Seconds())),
+			),
+		)
+	}
+}
+
+// RequestID returns the ID assigned to the request by Middleware.
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDContextKey)
+}
+
+// traceAttrs links log lines to the request's trace, preferring the span
+// started by the tracing middleware and falling back to Google's
+// X-Cloud-Trace-Context when tracing is not installed.
+func traceAttrs(projectID string, spanCtx trace.SpanContext, cloudTrace string) []any {
+	var traceID, spanID string
+	var sampled bool
+	if spanCtx.IsValid() {
+		traceID, spanID, sampled = spanCtx.TraceID().String(), spanCtx.SpanID().String(), spanCtx.IsSampled()
+	} else {
+		traceID, spanID, sampled = parseCloudTrace(cloudTrace)
+	}
+	if traceID == "" || projectID == "" {
+		return nil
+	}
+	attrs := []any{slog.String(traceKey, fmt.Sprintf("projects/%s/traces/%s", projectID, traceID))}
+	if spanID != "" {
+		attrs = append(attrs, slog.String(spanKey, spanID))
+	}
+	return append(attrs, slog.Bool(traceSampledKey, sampled))
+}
+
+// parseCloudTrace reads a header like <trace id>/<decimal span id>;o=1.
+func parseCloudTrace(header string) (string, string, bool) {
+	traceAndSpan, options, _ := strings.Cut(header, ";")
+	traceID, span, _ := strings.Cut(traceAndSpan, "/")
+	if traceID == "" {
+		return "", "", false
+	}
+	spanID := ""
+	if parsed, err := strconv.ParseUint(span, 10, 64); err == nil {
+		spanID = fmt.Sprintf("%016x", parsed)
+	}
+	return traceID, spanID, options == "o=1"
+}
@@ -0,0 +1,148 @@
+// Purpose:
+// Prometheus metrics for HTTP traffic, Gemini calls and scorecard extraction quality.
+// Exposed on /metrics for scraping.
+
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "api"
+
+// Parse outcomes. Incomplete scorecards are saved with is_completed false,
+// failed parses produce no scorecard at all.
+const (
+	ParseComplete   = "complete"
+	ParseIncomplete = "incomplete"
+	ParseFailed     = "failed"
+)
+
+// Registry holds every collector exported on /metrics.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	geminiDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gemini_request_duration_seconds",
+		Help:      "Gemini generation latency by model and result.",
+		// image generations routinely take several seconds
+		Buckets: []float64{0.5, 1, 2, 4, 8, 15, 30, 60},
+	}, []string{"model", "result"})
+
+	geminiErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gemini_errors_total",
+		Help:      "Failed Gemini generations by model.",
+	}, []string{"model"})
+
+	geminiTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gemini_tokens_total",
+		Help:      "Gemini tokens used by model and kind (prompt, output).",
+	}, []string{"model", "kind"})
+
+	parseOutcomes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scorecard_parse_outcomes_total",
+		Help:      "Scorecard parses by game, outcome (complete, incomplete, failed) and reason.",
+	}, []string{"game", "outcome", "reason"})
+
+	imageUploadBytes = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "image_upload_bytes",
+		Help:      "Size of uploaded scorecard images.",
+		// 64KiB to 16MiB
+		Buckets: prometheus.ExponentialBuckets(64<<10, 2, 9),
+	})
+
+	scorecardsCreated = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "scorecards_created_total",
+		Help:      "Scorecards saved by game.",
+	}, []string{"game"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		geminiDuration,
+		geminiErrors,
+		geminiTokens,
+		parseOutcomes,
+		imageUploadBytes,
+		scorecardsCreated,
+	)
+}
+
+// Middleware records the count and latency of every request. Requests that
+// match no route share one label so scanners cannot blow up cardinality.
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		status := strconv.Itoa(c.Writer.Status())
+		httpRequests.WithLabelValues(c.Request.Method, route, status).Inc()
+		httpDuration.WithLabelValues(c.Request.Method, route, status).Observe(time.Since(start).Seconds())
+	}
+}
+
+// Handler serves the registry in the Prometheus text format.
+func Handler() gin.HandlerFunc {
+	return gin.WrapH(promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry}))
+}
+
+// ObserveGemini records one Gemini call.
+func ObserveGemini(model string, duration time.Duration, promptTokens, outputTokens int32, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+		geminiErrors.WithLabelValues(model).Inc()
+	}
+	geminiDuration.WithLabelValues(model, result).Observe(duration.Seconds())
+	geminiTokens.WithLabelValues(model, "prompt").Add(float64(promptTokens))
+	geminiTokens.WithLabelValues(model, "output").Add(float64(outputTokens))
+}
+
+// ObserveParse records how well a scorecard was extracted. reason is empty
+// for complete parses.
+func ObserveParse(game, outcome, reason string) {
+	parseOutcomes.WithLabelValues(game, outcome, reason).Inc()
+}
+
+// ObserveImageUpload records the size of an uploaded image.
+func ObserveImageUpload(bytes int) {
+	imageUploadBytes.Observe(float64(bytes))
+}
+
+// ObserveScorecardCreated counts a saved scorecard.
+func ObserveScorecardCreated(game string) {
+	scorecardsCreated.WithLabelValues(game).Inc()
+}
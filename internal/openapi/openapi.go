@@ -0,0 +1,126 @@
+// Purpose:
+// Serves the OpenAPI description of the API and an interactive docs page.
+// The spec is written by hand in openapi.yaml and checked against the router in its tests.
+
+package openapi
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed openapi.yaml
+var specYAML []byte
+
+//go:embed docs.html
+var docsHTML []byte
+
+// swaggerUI is the pinned Swagger UI release docs.html loads. Bumping it means
+// editing both places.
+const swaggerUI = "https://unpkg.com/swagger-ui-dist@5.17.14/"
+
+// inlineScript matches the script that starts Swagger UI in docs.html.
+var inlineScript = regexp.MustCompile(`(?s)<script>(.*?)</script>`)
+
+// docsPolicy only lets the docs page run the pinned Swagger UI and its own
+// inline script, so a changed or compromised CDN path is refused by the browser.
+var docsPolicy = contentSecurityPolicy(docsHTML)
+
+// routeParam matches gin path parameters such as :game.
+var routeParam = regexp.MustCompile(`:([A-Za-z0-9_]+)`)
+
+// Spec is a parsed OpenAPI document.
+type Spec struct {
+	json  []byte
+	paths map[string]map[string]any
+}
+
+// Load parses the embedded spec.
+func Load() (*Spec, error) {
+	var doc map[string]any
+	if err := yaml.Unmarshal(specYAML, &doc); err != nil {
+		return nil, fmt.Errorf("parsing openapi.yaml: %w", err)
+	}
+	encoded, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("encoding openapi spec: %w", err)
+	}
+
+	spec := &Spec{json: encoded, paths: map[string]map[string]any{}}
+	paths, _ := doc["paths"].(map[string]any)
+	for path, item := range paths {
+		operations, ok := item.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("openapi path %s is not an object", path)
+		}
+		spec.paths[path] = operations
+	}
+	return spec, nil
+}
+
+// Missing returns the routes, as "METHOD /path", that have no operation in
+// the spec. Only routes under one of prefixes are considered.
+func (s *Spec) Missing(routes gin.RoutesInfo, prefixes ...string) []string {
+	var missing []string
+	for _, route := range routes {
+		if !hasPrefix(route.Path, prefixes) {
+			continue
+		}
+		path := routeParam.ReplaceAllString(route.Path, "{$1}")
+		if _, ok := s.paths[path][strings.ToLower(route.Method)]; !ok {
+			missing = append(missing, route.Method+" "+route.Path)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+func hasPrefix(path string, prefixes []string) bool {
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// HandleSpec serves the spec as JSON.
+func HandleSpec(s *Spec) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json", s.json)
+	}
+}
+
+// HandleDocs serves a page that renders the spec with Swagger UI.
+func HandleDocs() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Content-Security-Policy", docsPolicy)
+		c.Data(http.StatusOK, "text/html; charset=utf-8", docsHTML)
+	}
+}
+
+func contentSecurityPolicy(page []byte) string {
+	scripts := []string{swaggerUI}
+	for _, match := range inlineScript.FindAllSubmatch(page, -1) {
+		sum := sha256.Sum256(match[1])
+		scripts = append(scripts, "'sha256-"+base64.StdEncoding.EncodeToString(sum[:])+"'")
+	}
+	return strings.Join([]string{
+		"default-src 'none'",
+		"script-src " + strings.Join(scripts, " "),
+		// swagger ui sets inline styles on the elements it renders
+		"style-src " + swaggerUI + " 'unsafe-inline'",
+		"img-src 'self' data:",
+		"connect-src 'self'",
+	}, "; ")
+}
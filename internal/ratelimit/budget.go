@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/firestore"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const llmUsageCollection = "llm-usage"
+
+// LLMUsageDocument counts a user's LLM calls on a single UTC day.
+type LLMUsageDocument struct {
+	Email     string    `firestore:"email" json:"email"`
+	Date      string    `firestore:"date" json:"date"`
+	Count     int       `firestore:"count" json:"count"`
+	UpdatedAt time.Time `firestore:"updated_at" json:"updated_at"`
+}
+
+// LLMBudget limits how many LLM calls each user can make per UTC day. Usage is
+// kept in Firestore so the budget holds across instances and restarts.
+type LLMBudget struct {
+	client *firestore.Client
+	perDay int
+}
+
+// NewLLMBudget creates a budget of perDay calls per user. A budget of zero or
+// less disables the check.
+func NewLLMBudget(client *firestore.Client, perDay int) *LLMBudget {
+	return &LLMBudget{client: client, perDay: perDay}
+}
+
+// Consume records one call for email, reporting false and the time until the
+// budget resets when the user has none left.
+func (b *LLMBudget) Consume(ctx context.Context, email string) (bool, time.Duration, error) {
+	now := time.Now().UTC()
+	day := now.Format(time.DateOnly)
+	reference := b.client.Collection(llmUsageCollection).Doc(fmt.Sprintf("%s_%s", day, email))
+
+	allowed := false
+	err := b.client.RunTransaction(ctx, func(ctx context.Context, tx *firestore.Transaction) error {
+		usage := LLMUsageDocument{Email: email, Date: day}
+		snapshot, err := tx.Get(reference)
+		if err != nil && status.Code(err) != codes.NotFound {
+			return err
+		}
+		if err == nil {
+			if err := snapshot.DataTo(&usage); err != nil {
+				return err
+			}
+		}
+
+		allowed = usage.Count < b.perDay
+		if !allowed {
+			return nil
+		}
+		usage.Count++
+		usage.UpdatedAt = now
+		return tx.Set(reference, usage)
+	})
+	if err != nil {
+		return false, 0, fmt.Errorf("failed to record llm usage: %w", err)
+	}
+
+	if !allowed {
+		midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+		return false, midnight.Sub(now), nil
+	}
+	return true, 0, nil
+}
+
+// Spend records one call for email, returning a rate limited error once the
+// user's budget for the day is used up. Callers spend it right before calling
+// the LLM, so rejected requests cost nothing. A nil budget never runs out.
+func (b *LLMBudget) Spend(ctx context.Context, email string) error {
+	if b == nil || b.perDay <= 0 {
+		return nil
+	}
+	return spendError(b.Consume(ctx, email))
+}
+
+// spendError turns the result of Consume into the error Spend returns.
+func spendError(allowed bool, retryAfter time.Duration, err error) error {
+	if err != nil {
+		return apierror.Internal("unable to check llm budget", err)
+	}
+	if !allowed {
+		return apierror.RateLimited("llm_budget_exhausted", "daily llm budget exhausted").WithRetryAfter(retryAfter)
+	}
+	return nil
+}
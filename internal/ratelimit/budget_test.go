@@ -0,0 +1,48 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+)
+
+func TestLLMBudgetSpend(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// budgets that are missing or disabled never reach firestore
+	var missing *LLMBudget
+	if err := missing.Spend(context.Background(), "a@example.com"); err != nil {
+		t.Errorf("nil budget: %v", err)
+	}
+	if err := NewLLMBudget(nil, 0).Spend(context.Background(), "a@example.com"); err != nil {
+		t.Errorf("disabled budget: %v", err)
+	}
+
+	if err := spendError(true, 0, nil); err != nil {
+		t.Errorf("allowed: %v", err)
+	}
+	if got := apierror.From(spendError(false, 0, errors.New("firestore unavailable"))).Status(); got != http.StatusInternalServerError {
+		t.Errorf("failed lookup = %d, want 500", got)
+	}
+
+	// an exhausted budget is reported as 429 with the time until midnight
+	r := gin.New()
+	r.Use(apierror.Middleware())
+	r.POST("/parses", func(c *gin.Context) {
+		apierror.Abort(c, spendError(false, 90*time.Minute+time.Second, nil))
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/parses", nil))
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "5401" {
+		t.Errorf("Retry-After = %q, want 5401", got)
+	}
+}
@@ -0,0 +1,88 @@
+// Purpose:
+// Parses the token bucket limits the rate limiter enforces.
+// Kept free of middleware so config can parse limits while loading.
+
+package limit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+// Limit is a token bucket size and refill rate.
+type Limit struct {
+	Rate  rate.Limit
+	Burst int
+}
+
+// Parse parses limits of the form "10/m" or "10/m:20", meaning ten requests
+// per minute with an optional burst of twenty. Units are s, m and h.
+func Parse(value string) (Limit, error) {
+	spec, burstSpec, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+	countSpec, unitSpec, ok := strings.Cut(spec, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid limit %q, expected <count>/<unit>", value)
+	}
+
+	count, err := strconv.Atoi(countSpec)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid count in limit %q", value)
+	}
+	var per time.Duration
+	switch unitSpec {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid unit in limit %q, expected s, m or h", value)
+	}
+
+	burst := count
+	if hasBurst {
+		burst, err = strconv.Atoi(burstSpec)
+		if err != nil || burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid burst in limit %q", value)
+		}
+	}
+	return Limit{Rate: rate.Every(per / time.Duration(count)), Burst: burst}, nil
+}
+
+// ParseRoutes parses semicolon separated "<METHOD> <route>=<limit>" pairs,
+// where route is the gin route pattern, e.g.
+// "POST /api/v1/board-game-tracker/parse-score-card-from-image/:game=5/m".
+// Limits are keyed by RouteKey.
+func ParseRoutes(value string) (map[string]Limit, error) {
+	limits := make(map[string]Limit)
+	for _, entry := range strings.Split(value, ";") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		route, limitSpec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid route limit %q, expected <METHOD> <route>=<limit>", entry)
+		}
+		method, path, ok := strings.Cut(strings.TrimSpace(route), " ")
+		if !ok {
+			return nil, fmt.Errorf("invalid route %q, expected <METHOD> <route>", route)
+		}
+		limit, err := Parse(limitSpec)
+		if err != nil {
+			return nil, err
+		}
+		limits[RouteKey(strings.ToUpper(method), strings.TrimSpace(path))] = limit
+	}
+	return limits, nil
+}
+
+// RouteKey identifies a route by method and gin route pattern.
+func RouteKey(method, path string) string {
+	return method + " " + path
+}
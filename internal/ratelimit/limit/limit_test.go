@@ -0,0 +1,79 @@
+package limit
+
+import (
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "10/m", want: Limit{Rate: rate.Every(6 * time.Second), Burst: 10}},
+		{value: " 2/s ", want: Limit{Rate: rate.Every(500 * time.Millisecond), Burst: 2}},
+		{value: "10/m:20", want: Limit{Rate: rate.Every(6 * time.Second), Burst: 20}},
+		{value: "1/h", want: Limit{Rate: rate.Every(time.Hour), Burst: 1}},
+		{value: "10", wantErr: true},
+		{value: "", wantErr: true},
+		{value: "ten/m", wantErr: true},
+		{value: "0/m", wantErr: true},
+		{value: "-1/m", wantErr: true},
+		{value: "10/d", wantErr: true},
+		{value: "10/M", wantErr: true},
+		{value: "10/m:0", wantErr: true},
+		{value: "10/m:x", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := Parse(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("Parse(%q) = %+v, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %+v, want %+v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseRoutes(t *testing.T) {
+	limits, err := ParseRoutes(" post /api/v2/parses=10/m ; GET /api/v1/board-game-tracker/ratings=1/s:5;")
+	if err != nil {
+		t.Fatalf("ParseRoutes: %v", err)
+	}
+	if len(limits) != 2 {
+		t.Fatalf("ParseRoutes = %v, want two routes", limits)
+	}
+	// methods are upper cased so overrides match the request method
+	if got := limits[RouteKey("POST", "/api/v2/parses")]; got.Burst != 10 {
+		t.Errorf("POST /api/v2/parses = %+v, want a burst of 10", got)
+	}
+	if got := limits[RouteKey("GET", "/api/v1/board-game-tracker/ratings")]; got.Burst != 5 || got.Rate != rate.Every(time.Second) {
+		t.Errorf("GET ratings = %+v, want 1/s with a burst of 5", got)
+	}
+
+	if limits, err := ParseRoutes(""); err != nil || len(limits) != 0 {
+		t.Errorf("ParseRoutes(\"\") = %v, %v, want no routes", limits, err)
+	}
+
+	for _, value := range []string{
+		"POST /api/v2/parses",
+		"/api/v2/parses=10/m",
+		"POST /api/v2/parses=10/d",
+		"POST /api/v2/parses=10/m;GET /api/v2/scorecards",
+	} {
+		if _, err := ParseRoutes(value); err == nil {
+			t.Errorf("ParseRoutes(%q) succeeded, want an error", value)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit/limit"
+	"golang.org/x/time/rate"
+)
+
+// idleBucketTTL is how long an unused bucket is kept before it is dropped.
+const idleBucketTTL = 10 * time.Minute
+
+type bucket struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// Limiter keeps a token bucket per route and caller, where the caller is the
+// authenticated user when there is one and the client IP otherwise, and a
+// bucket per client IP shared by every route.
+type Limiter struct {
+	defaultLimit limit.Limit
+	routeLimits  map[string]limit.Limit
+	ipLimit      limit.Limit
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+func NewLimiter(defaultLimit limit.Limit, routeLimits map[string]limit.Limit, ipLimit limit.Limit) *Limiter {
+	return &Limiter{
+		defaultLimit: defaultLimit,
+		routeLimits:  routeLimits,
+		ipLimit:      ipLimit,
+		buckets:      make(map[string]*bucket),
+		lastSweep:    time.Now(),
+	}
+}
+
+// reserve takes a token for key, returning how long to wait if none is left.
+func (l *Limiter) reserve(key string, bucketLimit limit.Limit) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) > idleBucketTTL {
+		for k, b := range l.buckets {
+			if now.Sub(b.lastSeen) > idleBucketTTL {
+				delete(l.buckets, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{limiter: rate.NewLimiter(bucketLimit.Rate, bucketLimit.Burst)}
+		l.buckets[key] = b
+	}
+	b.lastSeen = now
+
+	reservation := b.limiter.ReserveN(now, 1)
+	if delay := reservation.DelayFrom(now); delay > 0 {
+		reservation.CancelAt(now)
+		return false, delay
+	}
+	return true, 0
+}
+
+// Limit returns a Gin middleware enforcing the route's limit. Mount it after
+// the auth middleware so authenticated callers are limited per user.
+func (l *Limiter) Limit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := limit.RouteKey(c.Request.Method, c.FullPath())
+		routeLimit, ok := l.routeLimits[route]
+		if !ok {
+			routeLimit = l.defaultLimit
+		}
+
+		subject := "ip:" + c.ClientIP()
+		if user, ok := auth.UserFromContext(c); ok {
+			subject = "user:" + user.Email
+		}
+
+		allowed, retryAfter := l.reserve(route+"|"+subject, routeLimit)
+		if !allowed {
+			apierror.Abort(c, apierror.RateLimited("", "rate limit exceeded").WithRetryAfter(retryAfter))
+			return
+		}
+		c.Next()
+	}
+}
+
+// LimitByIP returns a Gin middleware limiting each client IP across every
+// route it is mounted on. Mount it before the auth middleware, so requests
+// that fail authentication, such as guessed API tokens, are limited too.
+func (l *Limiter) LimitByIP() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		allowed, retryAfter := l.reserve("ip|"+c.ClientIP(), l.ipLimit)
+		if !allowed {
+			apierror.Abort(c, apierror.RateLimited("", "rate limit exceeded").WithRetryAfter(retryAfter))
+			return
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit/limit"
+)
+
+func TestLimitByIPRunsBeforeAuthentication(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	perMinute, err := limit.Parse("2/m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter := NewLimiter(perMinute, nil, perMinute)
+	r := gin.New()
+	r.Use(apierror.Middleware())
+	// stands in for the auth middleware rejecting a made up token
+	r.GET("/api", limiter.LimitByIP(), func(c *gin.Context) {
+		apierror.Abort(c, apierror.Unauthorized("", "unauthorized"))
+	})
+
+	call := func(remoteAddr string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(http.MethodGet, "/api", nil)
+		request.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, request)
+		return w
+	}
+	for i := 0; i < 2; i++ {
+		if w := call("192.0.2.1:1234"); w.Code != http.StatusUnauthorized {
+			t.Fatalf("request %d = %d, want 401", i+1, w.Code)
+		}
+	}
+	w := call("192.0.2.1:1234")
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request = %d, want 429", w.Code)
+	}
+	if seconds, err := strconv.Atoi(w.Header().Get("Retry-After")); err != nil || seconds < 1 {
+		t.Errorf("Retry-After = %q, want a whole number of seconds", w.Header().Get("Retry-After"))
+	}
+	// other clients have their own bucket
+	if w := call("192.0.2.2:1234"); w.Code != http.StatusUnauthorized {
+		t.Errorf("request from another ip = %d, want 401", w.Code)
+	}
+}
@@ -2,59 +2,140 @@ package router
 
 import (
 	"context"
+	"crypto/subtle"
 	"log"
+	"net/http"
 	"time"
 
 	boardgametracker "github.com/owen-crook/api-dot-owencrook-dot-com/internal/api/board-game-tracker"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/api/roles"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/api/tokens"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/config"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/deprecation"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/errorreport"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/health"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/idempotency"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/lifecycle"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/metrics"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/openapi"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/ratelimit"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/tracing"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/validation"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/firestore"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/gcs"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/pkg/gemini"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func SetupRouter(cfg *config.Config) *gin.Engine {
+const (
+	// roleCacheTTL bounds how long a role change can take to reach a running instance.
+	roleCacheTTL = 30 * time.Second
+	// sessionTTL is how long a browser stays logged in after /auth/login.
+	sessionTTL = 7 * 24 * time.Hour
+)
+
+// v1DeprecatedAt is when the v2 scorecard API replaced the verb-style v1 routes.
+var v1DeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+
+// SetupRouter builds the engine and every client it depends on. Clients and
+// background jobs are registered with lc so they can be drained on shutdown.
+func SetupRouter(cfg *config.Config, lc *lifecycle.Manager) *gin.Engine {
 	ctx := context.Background()
 
-	// initialize auth
-	if err := auth.Init(ctx, cfg.GoogleClientID); err != nil {
-		log.Fatalf("auth init failed: %v", err)
+	// tracing is installed before any client is created so they pick up the provider
+	shutdownTracing, err := tracing.Setup(ctx, tracing.Config{
+		Exporter:    cfg.TraceExporter,
+		SampleRatio: cfg.TraceSampleRatio,
+		Environment: cfg.Environment,
+	})
+	if err != nil {
+		log.Fatalf("tracing init failed: %v", err)
 	}
+	lc.OnClose("tracing", func() error {
+		// closers run after the shutdown deadline handling, so give the flush its own
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		return shutdownTracing(ctx)
+	})
 
-	r := gin.Default()
+	// request bodies are checked with our own binding tags
+	if err := validation.Register(); err != nil {
+		log.Fatalf("validation init failed: %v", err)
+	}
 
-	// manage cors
-	// Configure CORS middleware
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowOrigins = []string{"http://localhost:3000", "https://owencrook.com"}
-	corsConfig.AllowMethods = []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"}
-	corsConfig.AllowHeaders = []string{"Origin", "Content-Type", "Accept", "Authorization"}
-	corsConfig.ExposeHeaders = []string{"Content-Length"}
-	corsConfig.AllowCredentials = true
-	corsConfig.MaxAge = 12 * time.Hour // How long the preflight request can be cached
+	// panics are always logged, and forwarded when a sentry dsn is configured
+	errorSinks := errorreport.Sinks{errorreport.LogSink{}}
+	if cfg.SentryDSN != nil {
+		sentrySink := errorreport.NewHTTPSink(cfg.SentryDSN, cfg.Environment)
+		lc.OnClose("error report sink", sentrySink.Close)
+		errorSinks = append(errorSinks, sentrySink)
+	}
 
-	r.Use(cors.New(corsConfig))
+	firestoreClient, err := firestore.NewFirestoreClient(ctx, cfg.GCPProjectID, cfg.FirestoreDatabaseID)
+	if err != nil {
+		log.Fatalf("failed to initialize Firestore: %v", err)
+	}
+	lc.OnClose("firestore", firestoreClient.Close)
 
-	// health check
-	r.GET("/healthz", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// rate limits per client ip and per route and caller, plus a daily budget for calls that spend gemini quota
+	limiter := ratelimit.NewLimiter(cfg.RateLimitDefault, cfg.RateLimitRoutes, cfg.RateLimitIP)
+	llmBudget := ratelimit.NewLLMBudget(firestoreClient, cfg.LLMDailyBudget)
+	idempotencyStore := idempotency.NewStore(firestoreClient, cfg.IdempotencyTTL, cfg.WriteTimeout)
 
-	// setup route groups
-	v1RouteGroup := r.Group("/api/v1")
+	// roles are stored in firestore, with ADMIN_EMAILS kept as bootstrap admins
+	roleStore := auth.NewRoleStore(firestoreClient, cfg.AdminEmails, roleCacheTTL)
 
-	firestoreClient, err := firestore.NewFirestoreClient(ctx, cfg.GCPProjectID, cfg.FirestoreDatabaseID)
+	// personal api tokens and session cookies are accepted alongside id tokens
+	tokenStore := auth.NewTokenStore(firestoreClient)
+	sessionStore := auth.NewSessionStore(firestoreClient, sessionTTL)
+
+	// the dev issuer lets authenticated routes be exercised locally without google
+	var devIssuer *auth.DevIssuer
+	if cfg.DevIssuerEnabled {
+		devIssuer, err = auth.NewDevIssuer()
+		if err != nil {
+			log.Fatalf("dev issuer init failed: %v", err)
+		}
+		log.Println("Dev issuer enabled, mint tokens with POST /dev-issuer/token")
+	}
+
+	// initialize auth
+	authenticator, err := auth.NewAuthenticator(ctx, auth.AuthenticatorConfig{
+		Issuers:   cfg.OIDCIssuers,
+		Audiences: cfg.OIDCAudiences,
+		Roles:     roleStore,
+		Tokens:    tokenStore,
+		Sessions:  sessionStore,
+		DevIssuer: devIssuer,
+	})
 	if err != nil {
-		log.Fatalf("failed to initialize Firestore: %v", err)
+		log.Fatalf("auth init failed: %v", err)
+	}
+
+	// browser login via google, ending in a session cookie
+	loginFlow, err := auth.NewLoginFlow(ctx, auth.LoginConfig{
+		Issuer:                 "https://accounts.google.com",
+		ClientID:               cfg.GoogleClientID,
+		ClientSecret:           cfg.GoogleClientSecret,
+		RedirectURL:            cfg.GoogleRedirectURL,
+		AllowedRedirectOrigins: cfg.AuthRedirectOrigins,
+		SecureCookies:          cfg.Environment != "LOCAL",
+	}, authenticator, sessionStore)
+	if err != nil {
+		log.Fatalf("login flow init failed: %v", err)
 	}
 
 	googleCloudStorageClient, err := gcs.NewGCSClient(ctx)
 	if err != nil {
 		log.Fatalf("failed to initialize GCS: %v", err)
 	}
+	lc.OnClose("gcs", googleCloudStorageClient.Close)
 
 	log.Println("Creating board game tracker repository")
 	bgtRepository := boardgametracker.NewStorage(firestoreClient, googleCloudStorageClient)
@@ -66,12 +147,159 @@ func SetupRouter(cfg *config.Config) *gin.Engine {
 	if err != nil {
 		log.Fatal("gemini client is nil!")
 	}
+	lc.OnClose("gemini", geminiClient.Close)
+	geminiClient.SetObserver(func(call gemini.Call) {
+		metrics.ObserveGemini(call.Model, call.Duration, call.PromptTokens, call.OutputTokens, call.Err)
+	})
 
 	bgtService := &boardgametracker.ScoreService{
 		Repository:   bgtRepository,
 		GeminiClient: geminiClient,
+		LLMBudget:    llmBudget,
+		Jobs:         lc,
+	}
+	// rotated secrets are picked up without a restart when a refresh interval is set
+	if cfg.SecretRefreshInterval > 0 {
+		refresher := cfg.NewSecretRefresher(cfg.SecretRefreshInterval)
+		refresher.OnChange("GEMINI_API_KEY", func(value string) {
+			if err := geminiClient.SetAPIKey(context.Background(), value); err != nil {
+				log.Printf("Error applying rotated GEMINI_API_KEY: %v", err)
+			}
+		})
+		refresher.OnChange("GOOGLE_CLIENT_SECRET", loginFlow.SetClientSecret)
+		refresher.Start()
+		lc.OnClose("secret refresher", refresher.Stop)
 	}
-	log.Println("Registering boardgametracker routes")
-	boardgametracker.RegisterRoutes(cfg, v1RouteGroup, bgtService)
+
+	// readiness checks each dependency, the llm only by configuration unless a ping is enabled
+	checker := health.NewChecker(cfg.ReadinessCacheTTL, cfg.ReadinessTimeout, lc.Draining)
+	checker.Register("firestore", bgtRepository.CheckFirestore)
+	checker.Register("gcs", bgtRepository.CheckBucket)
+	checker.Register("llm", func(ctx context.Context) error {
+		if cfg.ReadinessLLMPing {
+			return geminiClient.Ping(ctx)
+		}
+		return geminiClient.CheckConfig()
+	})
+
+	spec, err := openapi.Load()
+	if err != nil {
+		log.Fatalf("openapi spec is invalid: %v", err)
+	}
+
+	// gin's text logger is replaced by structured access logs with request ids
+	// spans come first so access logs can be linked to the request's trace
+	r := gin.New()
+	// client ips are used to rate limit anonymous callers, so forwarded
+	// headers are only believed from proxies we run behind
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %v", err)
+	}
+	r.Use(otelgin.Middleware(tracing.ServiceName), logging.Middleware(cfg.GCPProjectID), metrics.Middleware(), errorreport.Recovery(errorSinks), apierror.Middleware())
+
+	// manage cors
+	// Configure CORS middleware
+	corsConfig := cors.DefaultConfig()
+	corsConfig.AllowOrigins = cfg.CORSAllowOrigins
+	corsConfig.AllowMethods = cfg.CORSAllowMethods
+	corsConfig.AllowHeaders = cfg.CORSAllowHeaders
+	corsConfig.ExposeHeaders = []string{"Content-Length", logging.RequestIDHeader, idempotency.ReplayedHeader, "Location", "Deprecation", "Sunset", "Link"}
+	corsConfig.AllowCredentials = true
+	corsConfig.MaxAge = 12 * time.Hour // How long the preflight request can be cached
+
+	r.Use(cors.New(corsConfig))
+
+	// cap request bodies, multipart image uploads included
+	r.Use(func(c *gin.Context) {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, cfg.MaxBodyBytes)
+		c.Next()
+	})
+
+	RegisterRoutes(r, Dependencies{
+		Config:           cfg,
+		Lifecycle:        lc,
+		Limiter:          limiter,
+		IdempotencyStore: idempotencyStore,
+		Authenticator:    authenticator,
+		RoleStore:        roleStore,
+		TokenStore:       tokenStore,
+		LoginFlow:        loginFlow,
+		DevIssuer:        devIssuer,
+		ScoreService:     bgtService,
+		Checker:          checker,
+		Spec:             spec,
+	})
 	return r
 }
+
+// Dependencies are what the routes are served with. Nothing is called while
+// routes are registered, so zero values are enough to inspect them, as the
+// spec coverage test does.
+type Dependencies struct {
+	Config           *config.Config
+	Lifecycle        *lifecycle.Manager
+	Limiter          *ratelimit.Limiter
+	IdempotencyStore *idempotency.Store
+	Authenticator    *auth.Authenticator
+	RoleStore        *auth.RoleStore
+	TokenStore       *auth.TokenStore
+	LoginFlow        *auth.LoginFlow
+	// DevIssuer is nil unless the dev issuer is enabled.
+	DevIssuer    *auth.DevIssuer
+	ScoreService *boardgametracker.ScoreService
+	Checker      *health.Checker
+	Spec         *openapi.Spec
+}
+
+// RegisterRoutes mounts every route on r. Every route under /api/ and /auth/
+// must be described in openapi.yaml, which router_test.go checks.
+func RegisterRoutes(r *gin.Engine, deps Dependencies) {
+	cfg, lc, limiter := deps.Config, deps.Lifecycle, deps.Limiter
+
+	// health check, failing while draining so traffic moves to other instances
+	r.GET("/healthz", func(c *gin.Context) {
+		if lc.Draining() {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"status": "draining"})
+			return
+		}
+		c.JSON(200, gin.H{"status": "ok"})
+	})
+	r.GET("/livez", health.HandleLive())
+	r.GET("/readyz", health.HandleReady(deps.Checker))
+
+	// prometheus scrape endpoint, optionally behind a static bearer token
+	r.GET("/metrics", func(c *gin.Context) {
+		if cfg.MetricsToken != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+cfg.MetricsToken)) != 1 {
+			apierror.Abort(c, apierror.Unauthorized("", "unauthorized"))
+			return
+		}
+		c.Next()
+	}, metrics.Handler())
+
+	// every client ip is limited before authentication, so callers that fail
+	// it still cannot make unlimited token lookups
+	ipLimit := limiter.LimitByIP()
+
+	if deps.DevIssuer != nil {
+		r.POST("/dev-issuer/token", ipLimit, limiter.Limit(), deps.DevIssuer.HandleMintToken())
+	}
+	deps.LoginFlow.RegisterRoutes(r, ipLimit, limiter.Limit())
+
+	// setup route groups
+	v1RouteGroup := r.Group("/api/v1", ipLimit)
+	v2RouteGroup := r.Group("/api/v2", ipLimit)
+
+	roles.RegisterRoutes(v1RouteGroup, deps.RoleStore, deps.Authenticator, limiter)
+	tokens.RegisterRoutes(v1RouteGroup, deps.TokenStore, deps.Authenticator, limiter)
+
+	boardgametracker.RegisterRoutes(v1RouteGroup, deps.ScoreService, deps.Authenticator, limiter, deps.IdempotencyStore, deprecation.Notice{
+		Since:  v1DeprecatedAt,
+		Sunset: cfg.APIV1Sunset,
+	})
+	boardgametracker.RegisterV2Routes(v2RouteGroup, deps.ScoreService, deps.Authenticator, limiter, deps.IdempotencyStore)
+
+	// the api description, which must cover every api and auth route so
+	// clients never have to guess at an undocumented one
+	v1RouteGroup.GET("/openapi.json", openapi.HandleSpec(deps.Spec))
+	v1RouteGroup.GET("/docs", openapi.HandleDocs())
+}
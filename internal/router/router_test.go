@@ -0,0 +1,32 @@
+package router
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/auth"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/config"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/openapi"
+)
+
+func TestRoutesAreDocumented(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	spec, err := openapi.Load()
+	if err != nil {
+		t.Fatalf("openapi spec is invalid: %v", err)
+	}
+
+	// routes are only inspected, so no dependency is ever called
+	r := gin.New()
+	RegisterRoutes(r, Dependencies{
+		Config:    &config.Config{},
+		LoginFlow: &auth.LoginFlow{},
+		DevIssuer: &auth.DevIssuer{},
+		Spec:      spec,
+	})
+
+	if missing := spec.Missing(r.Routes(), "/api/", "/auth/"); len(missing) > 0 {
+		t.Errorf("routes missing from openapi.yaml: %v", missing)
+	}
+}
@@ -0,0 +1,256 @@
+// Purpose:
+// Custom binding validators for request bodies and the mapping of binding
+// failures to field-level problem responses.
+
+package validation
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/apierror"
+	"github.com/owen-crook/board-game-tracker-go-common/pkg/gamedata"
+	"github.com/owen-crook/board-game-tracker-go-common/pkg/games"
+)
+
+// EarliestGameDate is the oldest date a game or session may be recorded for.
+var EarliestGameDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// gameDateSlack lets a game be recorded for "today" from any timezone.
+const gameDateSlack = 24 * time.Hour
+
+// playerScoreKeys are allowed on every player score besides the game's categories.
+var playerScoreKeys = []string{"id", "name", "total"}
+
+// Register adds the custom tags to gin's validator and makes field errors
+// use json names. It must run before any request is bound.
+//
+//	supported_game  the string is a game we can score
+//	game_date       the time is between EarliestGameDate and today
+//	scores_for=F    the player scores fit the game named by sibling field F
+func Register() error {
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return errors.New("gin is not using go-playground/validator")
+	}
+	engine.RegisterTagNameFunc(func(field reflect.StructField) string {
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		if name == "" {
+			return field.Name
+		}
+		return name
+	})
+
+	validators := map[string]validator.Func{
+		"supported_game": validateSupportedGame,
+		"game_date":      validateGameDate,
+		"scores_for":     validateScoresFor,
+	}
+	for tag, fn := range validators {
+		if err := engine.RegisterValidation(tag, fn); err != nil {
+			return fmt.Errorf("registering %s validator: %w", tag, err)
+		}
+	}
+	return nil
+}
+
+func validateSupportedGame(fl validator.FieldLevel) bool {
+	return fl.Field().Kind() == reflect.String && games.IsSupportedGame(games.Game(fl.Field().String()))
+}
+
+func validateGameDate(fl validator.FieldLevel) bool {
+	date, ok := fl.Field().Interface().(time.Time)
+	return ok && CheckGameDate(date) == nil
+}
+
+// CheckGameDate reports whether a game or session may be recorded for date.
+func CheckGameDate(date time.Time) error {
+	if date.Before(EarliestGameDate) || date.After(time.Now().Add(gameDateSlack)) {
+		return fmt.Errorf("must be between %s and today", EarliestGameDate.Format(time.DateOnly))
+	}
+	return nil
+}
+
+func validateScoresFor(fl validator.FieldLevel) bool {
+	game := fl.Parent().FieldByName(fl.Param())
+	if game.Kind() == reflect.Pointer {
+		if game.IsNil() {
+			return false
+		}
+		game = game.Elem()
+	}
+	scores, ok := fl.Field().Interface().([]map[string]any)
+	if !ok || game.Kind() != reflect.String {
+		return false
+	}
+	return CheckPlayerScores(game.String(), scores) == nil
+}
+
+// CheckPlayerScores reports the first way scores do not fit game: every
+// player needs a name and a whole number for each of the game's scoring
+// categories, and nothing else.
+func CheckPlayerScores(game string, scores []map[string]any) error {
+	categories, err := gamedata.GetScoringCategoriesByGame(games.Game(game))
+	if err != nil {
+		return fmt.Errorf("%s has no scoring categories", game)
+	}
+	allowed := map[string]bool{}
+	for _, key := range playerScoreKeys {
+		allowed[key] = true
+	}
+	for _, category := range categories {
+		allowed[category.ShortName] = true
+	}
+
+	for i, player := range scores {
+		name, _ := player["name"].(string)
+		if strings.TrimSpace(name) == "" {
+			return fmt.Errorf("player %d has no name", i+1)
+		}
+		for _, category := range categories {
+			value, ok := player[category.ShortName]
+			if !ok {
+				return fmt.Errorf("%s is missing %s", name, category.ShortName)
+			}
+			if !isWholeNumber(value) {
+				return fmt.Errorf("%s's %s must be a whole number", name, category.ShortName)
+			}
+		}
+		if total, ok := player["total"]; ok && !isWholeNumber(total) {
+			return fmt.Errorf("%s's total must be a whole number", name)
+		}
+		for key := range player {
+			if !allowed[key] {
+				return fmt.Errorf("%s has unknown category %s", name, key)
+			}
+		}
+	}
+	return nil
+}
+
+func isWholeNumber(value any) bool {
+	switch v := value.(type) {
+	case int, int32, int64:
+		return true
+	case float64:
+		return v == math.Trunc(v) && !math.IsInf(v, 0)
+	default:
+		return false
+	}
+}
+
+// Error turns a binding failure into a problem with a message per field.
+func Error(err error) *apierror.Error {
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	var timeErr *time.ParseError
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &validationErrs):
+		fields := map[string]string{}
+		for _, fieldErr := range validationErrs {
+			fields[fieldName(fieldErr)] = message(fieldErr)
+		}
+		return apierror.Validation("", describe(fields)).WithFields(fields)
+	case errors.As(err, &typeErr):
+		fields := map[string]string{typeErr.Field: "must be " + jsonType(typeErr.Type)}
+		return apierror.Validation("", describe(fields)).WithFields(fields)
+	case errors.As(err, &timeErr):
+		return apierror.Validation("invalid_body", fmt.Sprintf("dates must look like %s", time.RFC3339))
+	case errors.As(err, &tooLarge):
+		return apierror.PayloadTooLarge("", fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit))
+	case errors.Is(err, io.EOF):
+		return apierror.Validation("invalid_body", "request body is required")
+	default:
+		return apierror.Validation("invalid_body", "request body must be valid JSON")
+	}
+}
+
+// Field reports a single invalid field found after binding.
+func Field(field string, err error) *apierror.Error {
+	fields := map[string]string{field: err.Error()}
+	return apierror.Validation("", describe(fields)).WithFields(fields)
+}
+
+// fieldName is the json path of the field without the struct name, e.g.
+// player_scores rather than ScorecardRequest.player_scores.
+func fieldName(fieldErr validator.FieldError) string {
+	_, path, ok := strings.Cut(fieldErr.Namespace(), ".")
+	if !ok {
+		return fieldErr.Field()
+	}
+	return path
+}
+
+func message(fieldErr validator.FieldError) string {
+	switch fieldErr.Tag() {
+	case "required":
+		return "is required"
+	case "min", "max":
+		bound := "at least"
+		if fieldErr.Tag() == "max" {
+			bound = "at most"
+		}
+		switch fieldErr.Kind() {
+		case reflect.Slice, reflect.Map:
+			if fieldErr.Tag() == "min" && fieldErr.Param() == "1" {
+				return "must not be empty"
+			}
+			return fmt.Sprintf("must have %s %s items", bound, fieldErr.Param())
+		case reflect.String:
+			return fmt.Sprintf("must be %s %s characters", bound, fieldErr.Param())
+		default:
+			return fmt.Sprintf("must be %s %s", bound, fieldErr.Param())
+		}
+	case "email":
+		return "must be an email address"
+	case "supported_game":
+		return fmt.Sprintf("%v is not a supported game", fieldErr.Value())
+	case "game_date":
+		return fmt.Sprintf("must be between %s and today", EarliestGameDate.Format(time.DateOnly))
+	case "scores_for":
+		return "each player needs a name and a whole number for every scoring category of the game"
+	default:
+		return "is invalid"
+	}
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// describe summarizes the invalid fields for the problem detail.
+func describe(fields map[string]string) string {
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return "invalid " + strings.Join(names, ", ")
+}
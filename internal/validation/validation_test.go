@@ -0,0 +1,163 @@
+package validation
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/owen-crook/board-game-tracker-go-common/pkg/gamedata"
+	"github.com/owen-crook/board-game-tracker-go-common/pkg/games"
+)
+
+const testGame = "wingspan"
+
+type testPlayer struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type testRequest struct {
+	Game         string           `json:"game" binding:"required,supported_game"`
+	Date         time.Time        `json:"date" binding:"omitempty,game_date"`
+	Count        int              `json:"count" binding:"omitempty,min=1"`
+	Players      []testPlayer     `json:"players" binding:"omitempty,dive"`
+	PlayerScores []map[string]any `json:"player_scores" binding:"omitempty,scores_for=Game"`
+}
+
+// bindError is the status, detail and fields of the problem a request with
+// body, read with a limit of limit bytes, is rejected with. The status is 0
+// when the request binds.
+func bindError(t *testing.T, body string, limit int64) (int, string, map[string]string) {
+	t.Helper()
+	request := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	request.Body = http.MaxBytesReader(nil, request.Body, limit)
+	var input testRequest
+	err := binding.JSON.Bind(request, &input)
+	if err == nil {
+		return 0, "", nil
+	}
+	problem := Error(err)
+	return problem.Status(), problem.Detail, problem.Fields
+}
+
+func TestError(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		body   string
+		limit  int64
+		status int
+		detail string
+		fields map[string]string
+	}{
+		{"valid", `{"game":"wingspan","count":2}`, 1 << 10, 0, "", nil},
+		{"missing field", `{"count":2}`, 1 << 10, http.StatusBadRequest, "invalid game", map[string]string{"game": "is required"}},
+		{
+			"fields named by json path", `{"game":"monopoly","count":-1,"players":[{"name":"alice"},{}]}`, 1 << 10, http.StatusBadRequest, "invalid count, game, players[1].name",
+			map[string]string{"game": "monopoly is not a supported game", "count": "must be at least 1", "players[1].name": "is required"},
+		},
+		{"date out of range", `{"game":"wingspan","date":"1999-01-01T00:00:00Z"}`, 1 << 10, http.StatusBadRequest, "invalid date", map[string]string{"date": "must be between 2000-01-01 and today"}},
+		{"wrong json type", `{"game":"wingspan","count":"two"}`, 1 << 10, http.StatusBadRequest, "invalid count", map[string]string{"count": "must be an integer"}},
+		{"unparseable date", `{"game":"wingspan","date":"yesterday"}`, 1 << 10, http.StatusBadRequest, "dates must look like " + time.RFC3339, nil},
+		{"body over the limit", `{"game":"wingspan"}`, 4, http.StatusRequestEntityTooLarge, "request body must be at most 4 bytes", nil},
+		{"empty body", ``, 1 << 10, http.StatusBadRequest, "request body is required", nil},
+		{"malformed json", `{"game":`, 1 << 10, http.StatusBadRequest, "request body must be valid JSON", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, detail, fields := bindError(t, tt.body, tt.limit)
+			if status != tt.status || detail != tt.detail {
+				t.Errorf("got %d %q, want %d %q", status, detail, tt.status, tt.detail)
+			}
+			if len(fields) != len(tt.fields) {
+				t.Fatalf("fields = %v, want %v", fields, tt.fields)
+			}
+			for field, want := range tt.fields {
+				if fields[field] != want {
+					t.Errorf("fields[%s] = %q, want %q", field, fields[field], want)
+				}
+			}
+		})
+	}
+}
+
+// validScores is two players with a whole number for every category of game.
+func validScores(t *testing.T, game string) []map[string]any {
+	t.Helper()
+	categories, err := gamedata.GetScoringCategoriesByGame(games.Game(game))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var scores []map[string]any
+	for _, name := range []string{"alice", "bob"} {
+		player := map[string]any{"name": name, "total": float64(10)}
+		for _, category := range categories {
+			player[category.ShortName] = float64(1)
+		}
+		scores = append(scores, player)
+	}
+	return scores
+}
+
+func TestCheckPlayerScores(t *testing.T) {
+	categories, err := gamedata.GetScoringCategoriesByGame(games.Game(testGame))
+	if err != nil {
+		t.Fatal(err)
+	}
+	category := categories[0].ShortName
+
+	tests := []struct {
+		name   string
+		game   string
+		modify func([]map[string]any)
+		want   string
+	}{
+		{"valid", testGame, func([]map[string]any) {}, ""},
+		{"firestore integers", testGame, func(scores []map[string]any) { scores[0][category] = int64(3) }, ""},
+		{"unsupported game", "monopoly", func([]map[string]any) {}, "monopoly has no scoring categories"},
+		{"blank name", testGame, func(scores []map[string]any) { scores[1]["name"] = "  " }, "player 2 has no name"},
+		{"missing name", testGame, func(scores []map[string]any) { delete(scores[0], "name") }, "player 1 has no name"},
+		{"missing category", testGame, func(scores []map[string]any) { delete(scores[0], category) }, "alice is missing " + category},
+		{"fractional score", testGame, func(scores []map[string]any) { scores[1][category] = 2.5 }, "bob's " + category + " must be a whole number"},
+		{"score as a string", testGame, func(scores []map[string]any) { scores[1][category] = "3" }, "bob's " + category + " must be a whole number"},
+		{"fractional total", testGame, func(scores []map[string]any) { scores[0]["total"] = 10.5 }, "alice's total must be a whole number"},
+		{"unknown category", testGame, func(scores []map[string]any) { scores[0]["bonus"] = float64(1) }, "alice has unknown category bonus"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scores := validScores(t, testGame)
+			tt.modify(scores)
+			err := CheckPlayerScores(tt.game, scores)
+			got := ""
+			if err != nil {
+				got = err.Error()
+			}
+			if got != tt.want {
+				t.Errorf("err = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScoresForTag(t *testing.T) {
+	if err := Register(); err != nil {
+		t.Fatal(err)
+	}
+	scores := validScores(t, testGame)
+	scores[0]["total"] = 1.5
+	body, err := json.Marshal(testRequest{Game: testGame, PlayerScores: scores})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	status, _, fields := bindError(t, string(body), 1<<20)
+	if status != http.StatusBadRequest || fields["player_scores"] == "" {
+		t.Errorf("got %d with fields %v, want a 400 on player_scores", status, fields)
+	}
+}
@@ -0,0 +1,54 @@
+package elo
+
+import "math"
+
+const (
+	// DefaultRating is assigned to a player the first time they are seen.
+	DefaultRating = 1500.0
+	// DefaultK controls how far a single game can move a rating.
+	DefaultK = 32.0
+)
+
+// Result is one participant's rating going into a game and their final score.
+type Result struct {
+	Player string
+	Rating float64
+	Score  float64
+}
+
+// ExpectedScore returns the probability that a player rated a beats a player rated b.
+func ExpectedScore(a, b float64) float64 {
+	return 1 / (1 + math.Pow(10, (b-a)/400))
+}
+
+// Update applies a multiplayer Elo update. A game of N players is treated as
+// N-1 pairwise matches per player, with K scaled down so that a single game
+// moves a rating about as far as one head-to-head match would.
+func Update(results []Result, k float64) map[string]float64 {
+	updated := make(map[string]float64, len(results))
+	if len(results) < 2 {
+		for _, r := range results {
+			updated[r.Player] = r.Rating
+		}
+		return updated
+	}
+
+	scale := k / float64(len(results)-1)
+	for i, a := range results {
+		delta := 0.0
+		for j, b := range results {
+			if i == j {
+				continue
+			}
+			actual := 0.5
+			if a.Score > b.Score {
+				actual = 1
+			} else if a.Score < b.Score {
+				actual = 0
+			}
+			delta += actual - ExpectedScore(a.Rating, b.Rating)
+		}
+		updated[a.Player] = a.Rating + scale*delta
+	}
+	return updated
+}
@@ -0,0 +1,90 @@
+package elo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		results []Result
+		want    map[string]float64
+	}{
+		{
+			name:    "single player keeps their rating",
+			results: []Result{{Player: "alice", Rating: 1500, Score: 10}},
+			want:    map[string]float64{"alice": 1500},
+		},
+		{
+			name:    "winner of an even match takes half of k",
+			results: []Result{{Player: "alice", Rating: 1500, Score: 10}, {Player: "bob", Rating: 1500, Score: 8}},
+			want:    map[string]float64{"alice": 1516, "bob": 1484},
+		},
+		{
+			name:    "tie between equals changes nothing",
+			results: []Result{{Player: "alice", Rating: 1500, Score: 10}, {Player: "bob", Rating: 1500, Score: 10}},
+			want:    map[string]float64{"alice": 1500, "bob": 1500},
+		},
+		{
+			name:    "tie moves the higher rating down",
+			results: []Result{{Player: "alice", Rating: 1700, Score: 10}, {Player: "bob", Rating: 1500, Score: 10}},
+			want:    map[string]float64{"alice": 1700 - 32*(ExpectedScore(1700, 1500)-0.5), "bob": 1500 + 32*(ExpectedScore(1700, 1500)-0.5)},
+		},
+		{
+			name: "three players scale k by the two matches each plays",
+			results: []Result{
+				{Player: "alice", Rating: 1500, Score: 30},
+				{Player: "bob", Rating: 1500, Score: 20},
+				{Player: "carol", Rating: 1500, Score: 10},
+			},
+			want: map[string]float64{"alice": 1516, "bob": 1500, "carol": 1484},
+		},
+		{
+			name: "four players with a shared second place",
+			results: []Result{
+				{Player: "alice", Rating: 1500, Score: 30},
+				{Player: "bob", Rating: 1500, Score: 20},
+				{Player: "carol", Rating: 1500, Score: 20},
+				{Player: "dave", Rating: 1500, Score: 10},
+			},
+			want: map[string]float64{"alice": 1500 + 32.0*1.5/3, "bob": 1500, "carol": 1500, "dave": 1500 - 32.0*1.5/3},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Update(tt.results, DefaultK)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Update = %v, want %v", got, tt.want)
+			}
+			for player, want := range tt.want {
+				if math.Abs(got[player]-want) > 1e-9 {
+					t.Errorf("%s = %v, want %v", player, got[player], want)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateConservesRatingPoints(t *testing.T) {
+	results := []Result{
+		{Player: "alice", Rating: 1620, Score: 12},
+		{Player: "bob", Rating: 1480, Score: 31},
+		{Player: "carol", Rating: 1555, Score: 31},
+		{Player: "dave", Rating: 1390, Score: 7},
+		{Player: "erin", Rating: 1500, Score: 19},
+	}
+
+	before, after := 0.0, 0.0
+	updated := Update(results, DefaultK)
+	for _, result := range results {
+		before += result.Rating
+		after += updated[result.Player]
+	}
+	if math.Abs(before-after) > 1e-9 {
+		t.Errorf("ratings sum to %v after the game, want %v", after, before)
+	}
+	if updated["bob"] <= 1480 || updated["dave"] >= 1390 {
+		t.Errorf("Update = %v, want the joint winner up and the last place down", updated)
+	}
+}
@@ -23,6 +23,19 @@ func NewGCSClient(ctx context.Context) (*Client, error) {
 	}, nil
 }
 
+func (g *Client) Close() error {
+	return g.client.Close()
+}
+
+// CheckBucket fetches the bucket's attributes, confirming it exists and the
+// credentials can reach it.
+func (g *Client) CheckBucket(ctx context.Context, bucketName string) error {
+	if _, err := g.client.Bucket(bucketName).Attrs(ctx); err != nil {
+		return fmt.Errorf("failed to read GCS bucket attrs: %w", err)
+	}
+	return nil
+}
+
 func (g *Client) UploadFile(ctx context.Context, bucketName, objectPath string, data io.Reader, contentType string) error {
 	bucket := g.client.Bucket(bucketName)
 	object := bucket.Object(objectPath)
@@ -43,6 +56,24 @@ func (g *Client) UploadFile(ctx context.Context, bucketName, objectPath string,
 	return nil
 }
 
+func (g *Client) ReadFile(ctx context.Context, bucketName, objectPath string) ([]byte, error) {
+	bucket := g.client.Bucket(bucketName)
+	object := bucket.Object(objectPath)
+
+	reader, err := object.NewReader(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open GCS object: %w", err)
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read GCS object: %w", err)
+	}
+
+	return data, nil
+}
+
 func (g *Client) DeleteFile(ctx context.Context, bucketName, objectPath string) error {
 	bucket := g.client.Bucket(bucketName)
 	object := bucket.Object(objectPath)
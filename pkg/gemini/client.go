@@ -3,34 +3,113 @@ package gemini
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sync"
+	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"google.golang.org/genai"
 )
 
+// Call describes one finished generation, for metrics.
+type Call struct {
+	Model        string
+	Duration     time.Duration
+	PromptTokens int32
+	OutputTokens int32
+	Err          error
+}
+
 type Client struct {
+	model      string
+	httpClient *http.Client
+	observer   func(Call)
+
+	// mu guards the key and client, which are swapped when the key rotates
+	mu     sync.RWMutex
 	apiKey string
-	model  string
 	client *genai.Client
 }
 
 func NewClient(ctx context.Context, apiKey string, model string) (*Client, error) {
+	// keep our own http client so its connections can be released on Close,
+	// and trace its calls so slow generations show up as their own spans
+	c := &Client{model: model, httpClient: &http.Client{Transport: otelhttp.NewTransport(http.DefaultTransport)}}
+	if err := c.SetAPIKey(ctx, apiKey); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// SetAPIKey replaces the key used for new requests. Requests already in
+// flight finish with the old key.
+func (c *Client) SetAPIKey(ctx context.Context, apiKey string) error {
 	client, err := genai.NewClient(ctx, &genai.ClientConfig{
-		APIKey:  apiKey,
-		Backend: genai.BackendGeminiAPI,
+		APIKey:     apiKey,
+		Backend:    genai.BackendGeminiAPI,
+		HTTPClient: c.httpClient,
 	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to create Gemini client: %w", err)
+		return fmt.Errorf("failed to create Gemini client: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.apiKey = apiKey
+	c.client = client
+	return nil
+}
+
+// SetObserver registers fn to be called after every generation. It must be
+// set before the client is shared.
+func (c *Client) SetObserver(fn func(Call)) {
+	c.observer = fn
+}
+
+func (c *Client) current() (*genai.Client, string) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.client, c.apiKey
+}
+
+// Close releases idle connections. The genai client has no Close of its own.
+func (c *Client) Close() error {
+	c.httpClient.CloseIdleConnections()
+	return nil
+}
+
+// CheckConfig confirms the client was built with the settings it needs,
+// without calling the API.
+func (c *Client) CheckConfig() error {
+	client, apiKey := c.current()
+	if client == nil {
+		return fmt.Errorf("gemini client not initialized")
+	}
+	if apiKey == "" {
+		return fmt.Errorf("gemini api key not set")
 	}
+	if c.model == "" {
+		return fmt.Errorf("gemini model not set")
+	}
+	return nil
+}
 
-	return &Client{
-		apiKey: apiKey,
-		model:  model,
-		client: client,
-	}, nil
+// Ping looks up the configured model, which verifies the api key without
+// spending generation quota.
+func (c *Client) Ping(ctx context.Context) error {
+	if err := c.CheckConfig(); err != nil {
+		return err
+	}
+	client, _ := c.current()
+	if _, err := client.Models.Get(ctx, c.model, nil); err != nil {
+		return fmt.Errorf("gemini model lookup failed: %w", err)
+	}
+	return nil
 }
 
 func (c *Client) GenerateFromTextAndImage(ctx context.Context, prompt string, imageBytes []byte) (string, error) {
-	if c.client == nil {
+	client, _ := c.current()
+	if client == nil {
 		return "", fmt.Errorf("gemini client not initialized")
 	}
 
@@ -43,7 +122,16 @@ func (c *Client) GenerateFromTextAndImage(ctx context.Context, prompt string, im
 		genai.NewContentFromParts(parts, genai.RoleUser),
 	}
 
-	result, err := c.client.Models.GenerateContent(ctx, c.model, contents, nil)
+	start := time.Now()
+	result, err := client.Models.GenerateContent(ctx, c.model, contents, nil)
+	if c.observer != nil {
+		call := Call{Model: c.model, Duration: time.Since(start), Err: err}
+		if result != nil && result.UsageMetadata != nil {
+			call.PromptTokens = result.UsageMetadata.PromptTokenCount
+			call.OutputTokens = result.UsageMetadata.CandidatesTokenCount
+		}
+		c.observer(call)
+	}
 	if err != nil {
 		return "", fmt.Errorf("gemini image + text generation failed: %w", err)
 	}
@@ -0,0 +1,54 @@
+package helpers
+
+import (
+	"strings"
+	"unicode"
+)
+
+// NormalizeName lowercases s, drops punctuation and collapses whitespace so
+// that "Owen's  House" and "owens house" compare equal.
+func NormalizeName(s string) string {
+	var builder strings.Builder
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case unicode.IsLetter(r), unicode.IsDigit(r):
+			builder.WriteRune(r)
+		case unicode.IsSpace(r), r == '-', r == '_':
+			builder.WriteRune(' ')
+		}
+	}
+	return strings.Join(strings.Fields(builder.String()), " ")
+}
+
+// LevenshteinDistance returns the number of single rune edits needed to turn a into b.
+func LevenshteinDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
+
+// StringSimilarity scores a and b between 0 (nothing shared) and 1 (identical)
+// based on their edit distance relative to the longer string.
+func StringSimilarity(a, b string) float64 {
+	longest := max(len([]rune(a)), len([]rune(b)))
+	if longest == 0 {
+		return 1
+	}
+	return 1 - float64(LevenshteinDistance(a, b))/float64(longest)
+}
@@ -1,15 +1,94 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+
 	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/config"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/lifecycle"
+	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/logging"
 	"github.com/owen-crook/api-dot-owencrook-dot-com/internal/router"
 )
 
 func main() {
-	cfg := config.LoadConfig()
+	// json logs from the start, the level is applied once config is loaded.
+	// the standard log package is routed through the same handler.
+	logLevel := new(slog.LevelVar)
+	slog.SetDefault(logging.New(os.Stdout, logLevel))
+
+	configPath := flag.String("config", "", "path to a YAML or TOML config file, overridden by env vars")
+	flag.Parse()
+
+	// `server config check` validates the configuration and exits
+	if args := flag.Args(); len(args) > 0 {
+		if len(args) == 2 && args[0] == "config" && args[1] == "check" {
+			os.Exit(checkConfig(*configPath))
+		}
+		fmt.Fprintf(os.Stderr, "unknown command %q, expected \"config check\"\n", args)
+		os.Exit(2)
+	}
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		log.Fatalf("invalid configuration:\n%v", err)
+	}
+	logLevel.Set(cfg.LogLevel)
+	lc := lifecycle.NewManager(cfg.ShutdownDrainDelay)
 
 	// Setup router
-	r := router.SetupRouter(cfg)
+	r := router.SetupRouter(cfg, lc)
+
+	srv := &http.Server{
+		Addr:         config.GetListenAddress(*cfg),
+		Handler:      r,
+		ReadTimeout:  cfg.ReadTimeout,
+		WriteTimeout: cfg.WriteTimeout,
+		IdleTimeout:  cfg.IdleTimeout,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		log.Printf("Listening on %s", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	// cloud run sends SIGTERM and allows a short grace period before SIGKILL
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	select {
+	case err := <-serveErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("server failed: %v", err)
+		}
+	case sig := <-signals:
+		log.Printf("Received %s, draining after %s for up to %s", sig, cfg.ShutdownDrainDelay, cfg.ShutdownTimeout)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := lc.Shutdown(ctx, srv); err != nil {
+		log.Printf("Shutdown incomplete: %v", err)
+		return
+	}
+	log.Println("Shutdown complete")
+}
 
-	r.Run()
+// checkConfig loads the configuration and reports every problem found,
+// returning the process exit code.
+func checkConfig(path string) int {
+	if _, err := config.LoadConfig(path); err != nil {
+		fmt.Fprintf(os.Stderr, "configuration is invalid:\n%v\n", err)
+		return 1
+	}
+	fmt.Println("configuration is valid")
+	return 0
 }